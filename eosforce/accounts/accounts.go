@@ -0,0 +1,128 @@
+// Package accounts 抓取全部账号的余额。
+//
+// EOSForce 取 eosio accounts 表的可用余额，标准 eosio 取 voters 表的抵押额。
+package accounts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/config"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/metrics"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+var log = logging.New("accounts")
+
+// AccountInfo :
+type AccountInfo struct {
+	Account  string
+	Amount   uint64
+	Notified bool
+}
+
+// accountRow : EOSForce eosio accounts 表的一行
+type accountRow struct {
+	Available string `json:"available"`
+	Name      string `json:"name"`
+}
+
+// voterRow : 标准 eosio voters 表的一行，staked 已是最小单位
+type voterRow struct {
+	Owner  string `json:"owner"`
+	Staked uint64 `json:"staked"`
+}
+
+// AddTables : 在dbmap上注册 AccountInfo 表
+func AddTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(AccountInfo{}, "AccountInfo").SetKeys(false, "Account")
+}
+
+func getAccounts(nodeURL, table, account string) (*eosapi.RespGetTableRows, error) {
+	return eosapi.GetTableRows(nodeURL, &eosapi.TableQuery{
+		Code:       "eosio",
+		Scope:      "eosio",
+		Table:      table,
+		Limit:      500,
+		LowerBound: strconv.FormatUint(eosapi.StrToName(account), 10),
+	})
+}
+
+// parseRow : 按方言解析一行，返回账号和最小单位的余额
+func parseRow(dialect string, row json.RawMessage, precision int) (string, uint64, error) {
+	if dialect == config.DialectEOSIO {
+		var info voterRow
+		if err := json.Unmarshal(row, &info); nil != err {
+			return "", 0, err
+		}
+		return info.Owner, info.Staked, nil
+	}
+	var info accountRow
+	if err := json.Unmarshal(row, &info); nil != err {
+		return "", 0, err
+	}
+	avls := strings.Split(info.Available, " ")
+	lots, err := strconv.ParseFloat(avls[0], 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("strconv.ParseFloat(%s, 64) failed : %v", avls[0], err)
+	}
+	return info.Name, eosapi.ToUnits(lots, precision), nil
+}
+
+// FetchAll : 从头遍历余额表，把每个账号的余额写入 AccountInfo
+func FetchAll(dbmap *gorp.DbMap, nodeURL, dialect string, precision int) (err error) {
+	account := ""
+	table := "accounts"
+	if dialect == config.DialectEOSIO {
+		table = "voters"
+	}
+
+	for {
+		retry := 0
+		var respAcc *eosapi.RespGetTableRows
+		for ; retry < 10; retry++ {
+			respAcc, err = getAccounts(nodeURL, table, account)
+			if nil != err {
+				log.Warnf("getAllAccount - getAccounts failed : %v", err)
+				continue
+			}
+			break
+		}
+		if retry >= 10 {
+			log.Errorf("getAllAccount - getAccounts failed too many times, abort!")
+			return fmt.Errorf("getAllAccount failed too many times")
+		}
+
+		var name string
+		rows := make([][]interface{}, 0, len(respAcc.Rows))
+		for idx := range respAcc.Rows {
+			var amount uint64
+			if name, amount, err = parseRow(dialect, respAcc.Rows[idx], precision); nil != err {
+				log.Errorf("getAllAccount - row %s unrecognized : %v", respAcc.Rows[idx], err)
+				return err
+			}
+			log.Debugf("%-12s [%12d]", name, amount)
+			rows = append(rows, []interface{}{name, amount, false})
+		}
+		// 一页一批，PostgreSQL 下走 COPY
+		start := time.Now()
+		if err = store.BackendOf(dbmap).BulkUpsert(dbmap, "AccountInfo", []string{"Account"},
+			[]string{"Account", "Amount", "Notified"}, rows); nil != err {
+			log.Errorf("getAllAccount - save %d accounts failed : %v", len(rows), err)
+			return err
+		}
+		metrics.ObserveDB("account", start)
+		if !respAcc.More {
+			log.Infof("getAllAccount - done")
+			return nil
+		}
+		account = name
+		log.Infof("getAllAccount - from %s", account)
+	}
+}
@@ -0,0 +1,54 @@
+package accounts
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+// benchRows : 第 n 页的账号，和 getAllAccount 每页写入的一样
+func benchRows(n, size int) [][]interface{} {
+	rows := make([][]interface{}, size)
+	for idx := range rows {
+		rows[idx] = []interface{}{fmt.Sprintf("acct%d", n*size+idx), uint64(idx * 10000), false}
+	}
+	return rows
+}
+
+// BenchmarkSaveAccountInfo : 每次操作保存一页 get_table_rows 的账号，row 是逐条 UPSERT，batch 是 BulkUpsert
+func BenchmarkSaveAccountInfo(b *testing.B) {
+	const size = 1000
+	keys, cols := []string{"Account"}, []string{"Account", "Amount", "Notified"}
+	upsert := store.UpsertSQL("AccountInfo", keys, cols...)
+
+	b.Run("row", func(b *testing.B) {
+		dbmap, err := store.Open(filepath.Join(b.TempDir(), "accounts.db"), AddTables)
+		if nil != err {
+			b.Fatal(err)
+		}
+		defer dbmap.Db.Close()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			for _, row := range benchRows(n, size) {
+				if _, err = dbmap.Exec(upsert, row...); nil != err {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		dbmap, err := store.Open(filepath.Join(b.TempDir(), "accounts.db"), AddTables)
+		if nil != err {
+			b.Fatal(err)
+		}
+		defer dbmap.Db.Close()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			if err = store.BackendOf(dbmap).BulkUpsert(dbmap, "AccountInfo", keys, cols, benchRows(n, size)); nil != err {
+				b.Fatal(err)
+			}
+		}
+	})
+}
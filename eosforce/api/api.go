@@ -0,0 +1,316 @@
+// Package api 把 sqlite 中收集到的 VoteInfo 和 AccountInfo 通过 HTTP 以 json 提供查询。
+//
+//	GET /v1/bp/<bp>/voters?at=<时间>         BP 在某时刻的投票人，按额度从大到小
+//	GET /v1/bp/<bp>/total?at=<时间>          BP 在某时刻的投票总额和投票人数
+//	GET /v1/bps?at=<时间>                    所有 BP 在某时刻的投票总额
+//	GET /v1/voter/<voter>/history            投票人的全部投票记录，按时间倒序
+//	GET /v1/account/<account>                账号余额
+//	GET /v1/accounts/top                     余额最多的账号
+//
+// 时间格式同 voters.TimeLayout 或 RFC3339，不带 at 表示当前；也可以用 at_block=<块号> 按 block 截止，两者可以同时给。
+// 列表接口都接受 limit（默认100，最大1000）和 offset，返回 {"rows": [...], "more": bool}。
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/accounts"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+var log = logging.New("api")
+
+// 分页参数的默认值和上限
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Vote : 接口返回的一条投票
+type Vote struct {
+	SeqNum    uint64 `json:"seq_num"`
+	BlockNum  uint64 `json:"block_num"`
+	BlockTime string `json:"block_time"`
+	Voter     string `json:"voter"`
+	BP        string `json:"bp"`
+	Quantity  uint64 `json:"quantity"`
+	Symbol    string `json:"symbol"`
+}
+
+// Total : BP 的投票汇总
+type Total struct {
+	BP       string `json:"bp"`
+	Quantity uint64 `json:"quantity"`
+	Voters   int64  `json:"voters"`
+}
+
+// Account : 账号余额，Amount 为核心币的最小单位
+type Account struct {
+	Account  string `json:"account"`
+	Amount   uint64 `json:"amount"`
+	Notified bool   `json:"notified"`
+}
+
+// Page : 列表接口的返回
+type Page struct {
+	Rows interface{} `json:"rows"`
+	More bool        `json:"more"`
+}
+
+// Server : 查询接口
+type Server struct {
+	DB *gorp.DbMap
+}
+
+// httpError : 带 HTTP 状态码的错误
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &httpError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+// ServeHTTP : 按路径分发
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, &httpError{status: http.StatusMethodNotAllowed, msg: "only GET is supported"})
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var result interface{}
+	var err error
+	switch {
+	case len(parts) == 4 && parts[0] == "v1" && parts[1] == "bp" && parts[3] == "voters":
+		result, err = s.bpVoters(r, parts[2])
+	case len(parts) == 4 && parts[0] == "v1" && parts[1] == "bp" && parts[3] == "total":
+		result, err = s.bpTotal(r, parts[2])
+	case len(parts) == 2 && parts[0] == "v1" && parts[1] == "bps":
+		result, err = s.bpTotals(r)
+	case len(parts) == 4 && parts[0] == "v1" && parts[1] == "voter" && parts[3] == "history":
+		result, err = s.voterHistory(r, parts[2])
+	case len(parts) == 3 && parts[0] == "v1" && parts[1] == "account":
+		result, err = s.account(parts[2])
+	case len(parts) == 3 && parts[0] == "v1" && parts[1] == "accounts" && parts[2] == "top":
+		result, err = s.topAccounts(r)
+	default:
+		err = &httpError{status: http.StatusNotFound, msg: "unknown path " + r.URL.Path}
+	}
+	if nil != err {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(result); nil != err {
+		log.Errorf("api.ServeHTTP - encode %s failed : %v", r.URL.Path, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if he, ok := err.(*httpError); ok {
+		status = he.status
+	} else {
+		log.Infof("api - %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// page : 解析 limit/offset，多查一行用来判断 more
+func page(r *http.Request) (limit, offset int, err error) {
+	limit, offset = DefaultLimit, 0
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); nil != err || limit <= 0 {
+			return 0, 0, badRequest("limit '%s' invalid", v)
+		}
+		if limit > MaxLimit {
+			limit = MaxLimit
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); nil != err || offset < 0 {
+			return 0, 0, badRequest("offset '%s' invalid", v)
+		}
+	}
+	return limit, offset, nil
+}
+
+// at : 解析 at 和 at_block 参数，都没有则为当前
+func at(r *http.Request) (voters.AsOf, error) {
+	var asOf voters.AsOf
+	q := r.URL.Query()
+	if v := q.Get("at_block"); v != "" {
+		num, err := strconv.ParseUint(v, 10, 64)
+		if nil != err || num == 0 {
+			return asOf, badRequest("at_block '%s' invalid", v)
+		}
+		asOf.BlockNum = num
+	}
+	v := q.Get("at")
+	if v == "" {
+		return asOf, nil
+	}
+	if tm, err := time.Parse(voters.TimeLayout, v); nil == err {
+		asOf.Time = tm
+		return asOf, nil
+	}
+	if tm, err := time.Parse(time.RFC3339, v); nil == err {
+		asOf.Time = tm.UTC()
+		return asOf, nil
+	}
+	return asOf, badRequest("at '%s' invalid, should be like '%s'", v, voters.TimeLayout)
+}
+
+func toVotes(rows []*voters.VoteInfo) []*Vote {
+	votes := make([]*Vote, 0, len(rows))
+	for _, v := range rows {
+		votes = append(votes, &Vote{
+			SeqNum:    v.SeqNum,
+			BlockNum:  v.BlockNum,
+			BlockTime: v.BlockTime.Format(voters.TimeLayout),
+			Voter:     v.Voter,
+			BP:        v.BPName,
+			Quantity:  v.Quantity,
+			Symbol:    v.Symbol,
+		})
+	}
+	return votes
+}
+
+func (s *Server) bpVoters(r *http.Request, bp string) (*Page, error) {
+	asOf, err := at(r)
+	if nil != err {
+		return nil, err
+	}
+	limit, offset, err := page(r)
+	if nil != err {
+		return nil, err
+	}
+	query, args := voters.StandingQuery(bp, asOf)
+	var rows []*voters.VoteInfo
+	if _, err = s.DB.Select(&rows, query+" ORDER BY v.Quantity DESC, v.Voter LIMIT ? OFFSET ?",
+		append(args, limit+1, offset)...); nil != err {
+		return nil, err
+	}
+	return votesPage(rows, limit), nil
+}
+
+func votesPage(rows []*voters.VoteInfo, limit int) *Page {
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	return &Page{Rows: toVotes(rows), More: more}
+}
+
+func (s *Server) bpTotal(r *http.Request, bp string) (*Total, error) {
+	asOf, err := at(r)
+	if nil != err {
+		return nil, err
+	}
+	total := &Total{BP: bp}
+	query, args := voters.StandingQuery(bp, asOf)
+	row := s.DB.Db.QueryRow("SELECT COALESCE(SUM(Quantity),0), COUNT(*) FROM ("+query+") t", args...)
+	if err = row.Scan(&total.Quantity, &total.Voters); nil != err {
+		return nil, err
+	}
+	return total, nil
+}
+
+func (s *Server) bpTotals(r *http.Request) (*Page, error) {
+	asOf, err := at(r)
+	if nil != err {
+		return nil, err
+	}
+	limit, offset, err := page(r)
+	if nil != err {
+		return nil, err
+	}
+	cond, args := asOf.Cond()
+	rows, err := s.DB.Db.Query(`SELECT v.BPName, SUM(v.Quantity), COUNT(*) FROM VoteInfo v JOIN
+	(SELECT Voter, BPName, MAX(SeqNum) AS Seq FROM VoteInfo WHERE 1=1`+cond+` GROUP BY Voter, BPName) l
+	ON v.SeqNum=l.Seq AND v.Voter=l.Voter AND v.BPName=l.BPName WHERE v.Quantity>0 GROUP BY v.BPName ORDER BY SUM(v.Quantity) DESC, v.BPName LIMIT ? OFFSET ?`,
+		append(args, limit+1, offset)...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	totals := []*Total{}
+	for rows.Next() {
+		var t Total
+		if err = rows.Scan(&t.BP, &t.Quantity, &t.Voters); nil != err {
+			return nil, err
+		}
+		totals = append(totals, &t)
+	}
+	if err = rows.Err(); nil != err {
+		return nil, err
+	}
+	more := len(totals) > limit
+	if more {
+		totals = totals[:limit]
+	}
+	return &Page{Rows: totals, More: more}, nil
+}
+
+func (s *Server) voterHistory(r *http.Request, voter string) (*Page, error) {
+	limit, offset, err := page(r)
+	if nil != err {
+		return nil, err
+	}
+	var rows []*voters.VoteInfo
+	if _, err = s.DB.Select(&rows, "SELECT * FROM VoteInfo WHERE Voter=? ORDER BY SeqNum DESC LIMIT ? OFFSET ?",
+		voter, limit+1, offset); nil != err {
+		return nil, err
+	}
+	return votesPage(rows, limit), nil
+}
+
+func (s *Server) account(name string) (*Account, error) {
+	var info accounts.AccountInfo
+	err := s.DB.SelectOne(&info, "SELECT * FROM AccountInfo WHERE Account=?", name)
+	if err == sql.ErrNoRows {
+		return nil, &httpError{status: http.StatusNotFound, msg: "account " + name + " not found"}
+	}
+	if nil != err {
+		return nil, err
+	}
+	return &Account{Account: info.Account, Amount: info.Amount, Notified: info.Notified}, nil
+}
+
+func (s *Server) topAccounts(r *http.Request) (*Page, error) {
+	limit, offset, err := page(r)
+	if nil != err {
+		return nil, err
+	}
+	var rows []*accounts.AccountInfo
+	if _, err = s.DB.Select(&rows, "SELECT * FROM AccountInfo ORDER BY Amount DESC, Account LIMIT ? OFFSET ?",
+		limit+1, offset); nil != err {
+		return nil, err
+	}
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	result := make([]*Account, 0, len(rows))
+	for _, info := range rows {
+		result = append(result, &Account{Account: info.Account, Amount: info.Amount, Notified: info.Notified})
+	}
+	return &Page{Rows: result, More: more}, nil
+}
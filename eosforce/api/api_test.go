@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/accounts"
+	"github.com/gpmn/eosutils/eosforce/store"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+// testServer : 临时 sqlite 库上的查询接口，已写入一组投票和余额
+func testServer(t *testing.T) *httptest.Server {
+	dbmap, err := store.Open(filepath.Join(t.TempDir(), "api.db"), voters.AddTables, accounts.AddTables)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbmap.Db.Close() })
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, v := range []struct {
+		seq      uint64
+		voter    string
+		bp       string
+		quantity uint64
+	}{
+		{1, "alice", "bp1", 100},
+		{2, "bob", "bp1", 200},
+		{3, "carol", "bp1", 50},
+		{4, "alice", "bp1", 300},
+		{5, "bob", "bp1", 0}, // 撤票
+		{6, "dave", "bp2", 150},
+		{7, "erin", "bp1", 80}, // 代理变化，两个投票人共用一个 SeqNum
+		{7, "frank", "bp1", 80},
+	} {
+		info := &voters.VoteInfo{SeqNum: v.seq, BlockNum: v.seq, Quantity: v.quantity, BlockTime: base.Add(time.Duration(v.seq) * time.Minute),
+			Voter: v.voter, BPName: v.bp, Symbol: "EOS"}
+		if err = voters.Save(dbmap, info); nil != err {
+			t.Fatal(err)
+		}
+	}
+	for _, a := range []accounts.AccountInfo{
+		{Account: "alice", Amount: 500, Notified: true},
+		{Account: "bob", Amount: 700},
+		{Account: "carol", Amount: 500},
+	} {
+		if err = dbmap.Insert(&a); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	srv := httptest.NewServer(&Server{DB: dbmap})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get : 请求 path，返回状态码，把 json 解析到 result
+func get(t *testing.T, srv *httptest.Server, path string, result interface{}) int {
+	resp, err := http.Get(srv.URL + path)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(resp.Body).Decode(result); nil != err {
+		t.Fatalf("GET %s : %v", path, err)
+	}
+	return resp.StatusCode
+}
+
+// testPage : 列表接口返回的投票
+type testPage struct {
+	Rows []*Vote `json:"rows"`
+	More bool    `json:"more"`
+}
+
+// names : 投票人和额度，便于比较
+func (p *testPage) names() []string {
+	var list []string
+	for _, v := range p.Rows {
+		list = append(list, fmt.Sprintf("%s:%s:%d", v.Voter, v.BP, v.Quantity))
+	}
+	return list
+}
+
+func TestVoters(t *testing.T) {
+	srv := testServer(t)
+	for _, c := range []struct {
+		path string
+		want []string
+		more bool
+	}{
+		{"/v1/bp/bp1/voters", []string{"alice:bp1:300", "erin:bp1:80", "frank:bp1:80", "carol:bp1:50"}, false},
+		{"/v1/bp/bp1/voters?limit=2", []string{"alice:bp1:300", "erin:bp1:80"}, true},
+		{"/v1/bp/bp1/voters?limit=2&offset=2", []string{"frank:bp1:80", "carol:bp1:50"}, false},
+		{"/v1/bp/bp1/voters?limit=2&offset=4", nil, false},
+		{"/v1/bp/bp1/voters?at_block=2", []string{"bob:bp1:200", "alice:bp1:100"}, false},
+		{"/v1/bp/bp1/voters?at_block=4", []string{"alice:bp1:300", "bob:bp1:200", "carol:bp1:50"}, false},
+		{"/v1/bp/bp1/voters?at=2024-01-01%2000:03:00", []string{"bob:bp1:200", "alice:bp1:100", "carol:bp1:50"}, false},
+		{"/v1/bp/bp1/voters?at=2024-01-01T00:05:00Z&at_block=3", []string{"bob:bp1:200", "alice:bp1:100", "carol:bp1:50"}, false},
+		{"/v1/bp/bp2/voters", []string{"dave:bp2:150"}, false},
+		{"/v1/voter/alice/history", []string{"alice:bp1:300", "alice:bp1:100"}, false},
+		{"/v1/voter/alice/history?limit=1", []string{"alice:bp1:300"}, true},
+		{"/v1/voter/alice/history?limit=1&offset=1", []string{"alice:bp1:100"}, false},
+	} {
+		t.Run(c.path, func(t *testing.T) {
+			var page testPage
+			if status := get(t, srv, c.path, &page); status != http.StatusOK {
+				t.Fatalf("status %d", status)
+			}
+			if got := page.names(); !reflect.DeepEqual(got, c.want) || page.More != c.more {
+				t.Errorf("got %v more %v, want %v more %v", got, page.More, c.want, c.more)
+			}
+		})
+	}
+}
+
+func TestTotals(t *testing.T) {
+	srv := testServer(t)
+	var total Total
+	if status := get(t, srv, "/v1/bp/bp1/total", &total); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if want := (Total{BP: "bp1", Quantity: 510, Voters: 4}); total != want {
+		t.Errorf("bp1 total %+v, want %+v", total, want)
+	}
+
+	var page struct {
+		Rows []Total `json:"rows"`
+		More bool    `json:"more"`
+	}
+	get(t, srv, "/v1/bps?limit=1", &page)
+	if want := []Total{{BP: "bp1", Quantity: 510, Voters: 4}}; !reflect.DeepEqual(page.Rows, want) || !page.More {
+		t.Errorf("bps page 1 %+v more %v", page.Rows, page.More)
+	}
+	get(t, srv, "/v1/bps?limit=1&offset=1", &page)
+	if want := []Total{{BP: "bp2", Quantity: 150, Voters: 1}}; !reflect.DeepEqual(page.Rows, want) || page.More {
+		t.Errorf("bps page 2 %+v more %v", page.Rows, page.More)
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	srv := testServer(t)
+	var page struct {
+		Rows []Account `json:"rows"`
+		More bool      `json:"more"`
+	}
+	get(t, srv, "/v1/accounts/top?limit=2", &page)
+	if want := []Account{{"bob", 700, false}, {"alice", 500, true}}; !reflect.DeepEqual(page.Rows, want) || !page.More {
+		t.Errorf("top page 1 %+v more %v", page.Rows, page.More)
+	}
+	get(t, srv, "/v1/accounts/top?limit=2&offset=2", &page)
+	if want := []Account{{"carol", 500, false}}; !reflect.DeepEqual(page.Rows, want) || page.More {
+		t.Errorf("top page 2 %+v more %v", page.Rows, page.More)
+	}
+
+	var account Account
+	if status := get(t, srv, "/v1/account/alice", &account); status != http.StatusOK || account != (Account{"alice", 500, true}) {
+		t.Errorf("account alice : status %d, %+v", status, account)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := testServer(t)
+	for _, c := range []struct {
+		path   string
+		status int
+	}{
+		{"/v1/account/nobody", http.StatusNotFound},
+		{"/v1/unknown", http.StatusNotFound},
+		{"/v1/bp/bp1/voters?limit=0", http.StatusBadRequest},
+		{"/v1/bp/bp1/voters?limit=x", http.StatusBadRequest},
+		{"/v1/bp/bp1/voters?offset=-1", http.StatusBadRequest},
+		{"/v1/bp/bp1/voters?at=yesterday", http.StatusBadRequest},
+		{"/v1/bp/bp1/voters?at_block=0", http.StatusBadRequest},
+	} {
+		var body map[string]string
+		if status := get(t, srv, c.path, &body); status != c.status || body["error"] == "" {
+			t.Errorf("GET %s : status %d, body %v, want status %d with error", c.path, status, body, c.status)
+		}
+	}
+
+	resp, err := http.Post(srv.URL+"/v1/bps", "application/json", nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST status %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,88 @@
+// Package budget 限制发送工具的总花费和发送速率，防止程序出错时耗尽发送账号。
+package budget
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrExhausted : 再发送就会超出金额或手续费预算
+var ErrExhausted = errors.New("budget exhausted")
+
+// ErrStopped : 等待速率限制时被要求停止
+var ErrStopped = errors.New("stopped")
+
+// Budget : 一次运行的花费上限，金额单位均为核心币的最小单位
+type Budget struct {
+	MaxAmount uint64 // 转出金额上限，0表示不允许转出金额
+	MaxFee    uint64 // 手续费上限，0表示不限制
+	Fee       uint64 // 每笔交易的手续费
+	PerMinute int    // 每分钟最多发送笔数，0表示不限制
+
+	mu     sync.Mutex
+	amount uint64
+	fees   uint64
+	sent   []time.Time
+}
+
+// New : 创建预算
+func New(maxAmount, maxFee, fee uint64, perMinute int) *Budget {
+	return &Budget{MaxAmount: maxAmount, MaxFee: maxFee, Fee: fee, PerMinute: perMinute}
+}
+
+// Spend : 为一笔金额为 amount 的交易预留预算。
+// 超出预算返回 ErrExhausted；超出速率时等待，等待中 stop 关闭则返回 ErrStopped。
+// 预留后不论发送成功与否都不退还，宁可少发也不多花。
+func (b *Budget) Spend(amount uint64, stop <-chan struct{}) error {
+	for {
+		b.mu.Lock()
+		if b.amount+amount > b.MaxAmount || (b.MaxFee > 0 && b.fees+b.Fee > b.MaxFee) {
+			b.mu.Unlock()
+			return ErrExhausted
+		}
+
+		now := time.Now()
+		for len(b.sent) > 0 && now.Sub(b.sent[0]) >= time.Minute {
+			b.sent = b.sent[1:]
+		}
+		if b.PerMinute <= 0 || len(b.sent) < b.PerMinute {
+			b.amount += amount
+			b.fees += b.Fee
+			if b.PerMinute > 0 {
+				b.sent = append(b.sent, now)
+			}
+			b.mu.Unlock()
+			return nil
+		}
+		wait := b.sent[0].Add(time.Minute).Sub(now)
+		b.mu.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-stop:
+			return ErrStopped
+		}
+	}
+}
+
+// Spent : 已预留的金额和手续费
+func (b *Budget) Spent() (amount, fees uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.amount, b.fees
+}
+
+// Backoff : 第 attempt 次（从0开始）失败后的等待时间，从1秒起每次翻倍，最多2分钟。
+// 实际等待在其一半到全值之间随机，避免多个进程或 goroutine 同时重试
+func Backoff(attempt int) time.Duration {
+	if attempt > 7 {
+		attempt = 7
+	}
+	wait := time.Second << uint(attempt)
+	if wait > 2*time.Minute {
+		wait = 2 * time.Minute
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)))
+}
@@ -0,0 +1,78 @@
+package budget
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpendCaps(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		budget  *Budget
+		amounts []uint64
+		want    []error
+		amount  uint64 // 最后已预留的金额
+		fees    uint64
+	}{
+		{"amount cap", New(100, 0, 1, 0), []uint64{40, 40, 40, 20}, []error{nil, nil, ErrExhausted, nil}, 100, 3},
+		{"fee cap", New(1000, 3, 2, 0), []uint64{1, 1}, []error{nil, ErrExhausted}, 1, 2},
+		{"fee cap exact", New(1000, 4, 2, 0), []uint64{1, 1, 1}, []error{nil, nil, ErrExhausted}, 2, 4},
+		{"no fee cap", New(1000, 0, 5, 0), []uint64{1, 1, 1}, []error{nil, nil, nil}, 3, 15},
+		{"zero amount cap", New(0, 0, 1, 0), []uint64{0, 1}, []error{nil, ErrExhausted}, 0, 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			for idx, amount := range c.amounts {
+				if err := c.budget.Spend(amount, nil); err != c.want[idx] {
+					t.Errorf("Spend #%d(%d) = %v, want %v", idx, amount, err, c.want[idx])
+				}
+			}
+			if amount, fees := c.budget.Spent(); amount != c.amount || fees != c.fees {
+				t.Errorf("Spent() = %d, %d, want %d, %d", amount, fees, c.amount, c.fees)
+			}
+		})
+	}
+}
+
+func TestSpendPerMinute(t *testing.T) {
+	b := New(1000, 0, 0, 2)
+	stop := make(chan struct{})
+	for idx := 0; idx < 2; idx++ {
+		if err := b.Spend(1, stop); nil != err {
+			t.Fatalf("Spend #%d = %v", idx, err)
+		}
+	}
+
+	// 第三笔要等到第一笔满一分钟，等待中停止不预留
+	close(stop)
+	if err := b.Spend(1, stop); err != ErrStopped {
+		t.Fatalf("Spend over rate = %v, want ErrStopped", err)
+	}
+	if amount, _ := b.Spent(); amount != 2 {
+		t.Errorf("Spent() = %d after stop, want 2", amount)
+	}
+
+	// 一分钟前发出的不再计数
+	b.mu.Lock()
+	b.sent[0] = time.Now().Add(-time.Minute)
+	b.mu.Unlock()
+	if err := b.Spend(1, nil); nil != err {
+		t.Errorf("Spend after window = %v", err)
+	}
+	if amount, _ := b.Spent(); amount != 3 {
+		t.Errorf("Spent() = %d, want 3", amount)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 12; attempt++ {
+		base := time.Second << uint(attempt)
+		if attempt > 7 || base > 2*time.Minute {
+			base = 2 * time.Minute
+		}
+		for idx := 0; idx < 100; idx++ {
+			if wait := Backoff(attempt); wait < base/2 || wait >= base {
+				t.Fatalf("Backoff(%d) = %v, want in [%v, %v)", attempt, wait, base/2, base)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package config 读取 eosutils 各子命令共用的配置文件。
+//
+// 配置为 TOML 格式，例如：
+//
+//	chain = "eosforce"
+//	db = "./eosutils.db"
+//
+//	[wallet]
+//	cleos = "/usr/local/bin/cleos"
+//	url = "http://127.0.0.1:8900"
+//
+//	[webhook]
+//	url = "https://example.com/eosutils/hook"
+//	secret = "用于 HMAC 签名的密钥"
+//
+//	[chains.eosforce]
+//	endpoints = ["https://w1.eosforce.cn", "https://w2.eosforce.cn", "https://w3.eosforce.cn"]
+//	core_symbol = "EOS"
+//	precision = 4
+//	dialect = "eosforce"
+//	token_contract = "eosio"
+//	block_interval_ms = 3000
+//	producer_repetitions = 1
+//
+// 文件中没有写的项使用 Default 中的值。
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gpmn/eosutils/eosforce/logging"
+)
+
+var log = logging.New("config")
+
+// 系统合约的方言
+const (
+	// DialectEOSForce : EOSForce 的 vote(voter, bpname, stake) 投票和 eosio accounts 余额表
+	DialectEOSForce = "eosforce"
+	// DialectEOSIO : 标准 eosio 的 voteproducer(voter, proxy, producers) 投票和 voters 表
+	DialectEOSIO = "eosio"
+)
+
+// Chain : 一条链的接入配置
+type Chain struct {
+	Name       string   `toml:"-"`
+	ChainID    string   `toml:"chain_id"` // 为空则不校验
+	Endpoints  []string `toml:"endpoints"`
+	CoreSymbol string   `toml:"core_symbol"`
+	Precision  int      `toml:"precision"`
+	Dialect    string   `toml:"dialect"`
+	Token      string   `toml:"token_contract"` // 核心币的合约，为空时按方言取 eosio 或 eosio.token
+
+	BlockIntervalMs     int `toml:"block_interval_ms"`    // 出块间隔，为0时按方言取 3000 或 500
+	ProducerRepetitions int `toml:"producer_repetitions"` // 每个 BP 每轮连续出块数，为0时按方言取 1 或 12
+}
+
+// BlockInterval : 出块间隔，也是一个出块 slot 的长度
+func (chain *Chain) BlockInterval() time.Duration {
+	return time.Duration(chain.BlockIntervalMs) * time.Millisecond
+}
+
+// Wallet : 发送交易用的 cleos 和 keosd
+type Wallet struct {
+	Cleos string `toml:"cleos"`
+	URL   string `toml:"url"`
+}
+
+// Webhook : watch 命令推送事件的地址和签名密钥
+type Webhook struct {
+	URL    string `toml:"url"`
+	Secret string `toml:"secret"`
+}
+
+// Config : 配置文件的内容
+type Config struct {
+	Chain   string            `toml:"chain"` // 默认使用的链
+	DB      string            `toml:"db"`    // 默认的库，sqlite 文件名或 postgres:// DSN
+	Wallet  Wallet            `toml:"wallet"`
+	Webhook Webhook           `toml:"webhook"`
+	Chains  map[string]*Chain `toml:"chains"`
+}
+
+// Default : 不读配置文件时的默认配置
+func Default() *Config {
+	return &Config{
+		Chain: "eosforce",
+		DB:    "./eosutils.db",
+		Wallet: Wallet{
+			Cleos: "/usr/local/bin/cleos",
+			URL:   "http://127.0.0.1:8900",
+		},
+		Chains: map[string]*Chain{
+			"eosforce": {
+				Endpoints:  []string{"https://w1.eosforce.cn", "https://w2.eosforce.cn", "https://w3.eosforce.cn"},
+				CoreSymbol: "EOS",
+				Precision:  4,
+				Dialect:    DialectEOSForce,
+			},
+			"eos": {
+				ChainID:    "aca376f206b8fc25a6ed44dbdc66547c36c6c33e3a119ffbeaef943642f0e906",
+				Endpoints:  []string{"http://mainnet.eoscalgary.io"},
+				CoreSymbol: "EOS",
+				Precision:  4,
+				Dialect:    DialectEOSIO,
+			},
+		},
+	}
+}
+
+// Load : 读取配置文件，path 为空时返回默认配置
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	var file Config
+	meta, err := toml.DecodeFile(path, &file)
+	if nil != err {
+		log.Errorf("config.Load - decode %s failed : %v", path, err)
+		return nil, err
+	}
+	for _, key := range meta.Undecoded() {
+		log.Warnf("config.Load - unknown key %s in %s", key.String(), path)
+	}
+
+	if file.Chain != "" {
+		cfg.Chain = file.Chain
+	}
+	if file.DB != "" {
+		cfg.DB = file.DB
+	}
+	if file.Wallet.Cleos != "" {
+		cfg.Wallet.Cleos = file.Wallet.Cleos
+	}
+	if file.Wallet.URL != "" {
+		cfg.Wallet.URL = file.Wallet.URL
+	}
+	cfg.Webhook = file.Webhook
+	for name, chain := range file.Chains {
+		cfg.Chains[name] = chain
+	}
+	return cfg, nil
+}
+
+// Profile : 取名为 name 的链配置，name 为空时取默认链
+func (cfg *Config) Profile(name string) (*Chain, error) {
+	if name == "" {
+		name = cfg.Chain
+	}
+	chain, ok := cfg.Chains[name]
+	if !ok {
+		var names []string
+		for n := range cfg.Chains {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown chain %s, should be one of %s", name, strings.Join(names, ", "))
+	}
+	if len(chain.Endpoints) == 0 {
+		return nil, fmt.Errorf("chain %s has no endpoints", name)
+	}
+	chain.Name = name
+	if chain.CoreSymbol == "" {
+		chain.CoreSymbol = "EOS"
+	}
+	if chain.Precision == 0 {
+		chain.Precision = 4
+	}
+	if chain.Dialect == "" {
+		chain.Dialect = DialectEOSIO
+	}
+	if chain.Dialect != DialectEOSForce && chain.Dialect != DialectEOSIO {
+		return nil, fmt.Errorf("chain %s has unknown dialect %s, should be %s or %s", name, chain.Dialect, DialectEOSForce, DialectEOSIO)
+	}
+	if chain.Token == "" {
+		chain.Token = "eosio.token"
+		if chain.Dialect == DialectEOSForce {
+			chain.Token = "eosio" // EOSForce 的核心币由系统合约直接转账
+		}
+	}
+	if chain.BlockIntervalMs == 0 {
+		chain.BlockIntervalMs = 500
+		if chain.Dialect == DialectEOSForce {
+			chain.BlockIntervalMs = 3000 // EOSForce 每 3 秒出一块
+		}
+	}
+	if chain.ProducerRepetitions == 0 {
+		chain.ProducerRepetitions = 12
+		if chain.Dialect == DialectEOSForce {
+			chain.ProducerRepetitions = 1 // EOSForce 每个 BP 每轮只出一块
+		}
+	}
+	return chain, nil
+}
+
+// NormalizeURL : 兼容只写主机名的老参数，如 w1.eosforce.cn
+func NormalizeURL(server string) string {
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	return strings.TrimRight(server, "/")
+}
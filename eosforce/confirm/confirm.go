@@ -0,0 +1,165 @@
+// Package confirm 跟踪已发出的交易，直到不可逆或过期。
+//
+// cleos 返回成功只说明交易被节点接受，交易仍可能因分叉或过期而没有上链。
+// 发送方用 Track 记录 trx_id，Poll 反复查询 get_transaction，
+// 把状态从 pending 推进到 irreversible / expired / failed，并回调发送方处理。
+package confirm
+
+import (
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+var log = logging.New("confirm")
+
+// 交易状态
+const (
+	StatusPending      = "pending"      // 已发送，尚未不可逆
+	StatusIrreversible = "irreversible" // 所在block已不可逆
+	StatusExpired      = "expired"      // 不可逆 block 的时间已过交易的过期时间，仍查不到
+	StatusFailed       = "failed"       // 上链但执行失败
+)
+
+// Tx : 一笔被跟踪的交易
+type Tx struct {
+	TrxID      string
+	Kind       string    // 发送方自定义的类别，如 adv / payout
+	Ref        string    // 发送方自定义的引用，如账号名、Payout.ID
+	BlockNum   uint64    // 所在block，未上链为0
+	Status     string    // 见 Status* 常量
+	SentAt     time.Time // 发送时间
+	Expiration time.Time // 签名交易里的过期时间，零值表示不知道，不会判定为过期
+	CheckedAt  time.Time // 最后一次查询时间
+}
+
+// AddTables : 在dbmap上注册 SentTx 表，调用方随后需执行 CreateTablesIfNotExists
+func AddTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(Tx{}, "SentTx").SetKeys(false, "TrxID")
+}
+
+// Track : 记录一笔刚发出的交易，expiration 取自签名的交易，exec 可以是事务，和发送方的状态更新一起提交
+func Track(exec gorp.SqlExecutor, kind, ref, trxID string, blockNum uint64, expiration time.Time) error {
+	now := time.Now()
+	_, err := exec.Exec(store.UpsertSQL("SentTx", []string{"TrxID"}, "TrxID", "Kind", "Ref", "BlockNum", "Status", "SentAt", "Expiration", "CheckedAt"),
+		trxID, kind, ref, blockNum, StatusPending, now, expiration, now)
+	return err
+}
+
+// Settled : 交易状态确定后的回调，exec 是和 SentTx 状态更新同一个事务，
+// 返回错误时事务回滚，该交易保持 pending，下次再处理
+type Settled func(exec gorp.SqlExecutor, tx *Tx) error
+
+// Poll : 检查一遍所有 pending 交易，返回仍为 pending 的数量。
+// 交易只能进入时间不晚于过期时间的 block，不可逆 block 的时间过了过期时间仍查不到才判定为过期；
+// history 插件可能还没同步到，发送方重发前仍要查链上记录
+func Poll(dbmap *gorp.DbMap, nodeURL string, onSettled Settled) (pending int, err error) {
+	var txs []*Tx
+	if _, err = dbmap.Select(&txs, "SELECT * FROM SentTx WHERE Status=? ORDER BY SentAt", StatusPending); nil != err {
+		log.Errorf("confirm.Poll - select SentTx failed : %v", err)
+		return 0, err
+	}
+	if len(txs) == 0 {
+		return 0, nil
+	}
+
+	info, err := eosapi.GetInfo(nodeURL)
+	if nil != err {
+		log.Warnf("confirm.Poll - eosapi.GetInfo failed : %v", err)
+		return len(txs), err
+	}
+	var libTime time.Time
+
+	for _, tx := range txs {
+		respTx, err := eosapi.GetTransaction(nodeURL, tx.TrxID)
+		if nil != err {
+			log.Warnf("confirm.Poll - eosapi.GetTransaction(%s) failed : %v", tx.TrxID, err)
+			pending++
+			continue
+		}
+
+		status := StatusPending
+		switch {
+		case respTx.ID == "":
+			if tx.Expiration.IsZero() {
+				break
+			}
+			if libTime.IsZero() {
+				if libTime, err = eosapi.GetBlockTime(nodeURL, info.LastIrreversibleBlockNum); nil != err {
+					log.Warnf("confirm.Poll - eosapi.GetBlockTime(%d) failed : %v", info.LastIrreversibleBlockNum, err)
+					return len(txs), err
+				}
+			}
+			if libTime.After(tx.Expiration) {
+				status = StatusExpired
+			}
+		case respTx.Trx.Receipt.Status != "" && respTx.Trx.Receipt.Status != "executed":
+			status = StatusFailed
+		case respTx.BlockNum > 0 && respTx.BlockNum <= info.LastIrreversibleBlockNum:
+			status = StatusIrreversible
+		}
+		if respTx.BlockNum > 0 {
+			tx.BlockNum = respTx.BlockNum
+		}
+		tx.CheckedAt = time.Now()
+
+		if status == StatusPending {
+			pending++
+			if _, err = dbmap.Exec("UPDATE SentTx SET BlockNum=?, CheckedAt=? WHERE TrxID=?",
+				tx.BlockNum, tx.CheckedAt, tx.TrxID); nil != err {
+				log.Errorf("confirm.Poll - update SentTx %s failed : %v", tx.TrxID, err)
+				return pending, err
+			}
+			continue
+		}
+
+		tx.Status = status
+		if err = settle(dbmap, tx, onSettled); nil != err {
+			log.Errorf("confirm.Poll - %s %s settle failed : %v", tx.Kind, tx.Ref, err)
+			pending++
+			continue
+		}
+		log.Debugf("confirm.Poll - %s %s trx %s %s", tx.Kind, tx.Ref, tx.TrxID, tx.Status)
+	}
+	return pending, nil
+}
+
+func settle(dbmap *gorp.DbMap, tx *Tx, onSettled Settled) error {
+	trans, err := dbmap.Begin()
+	if nil != err {
+		return err
+	}
+	if err = onSettled(trans, tx); nil != err {
+		trans.Rollback()
+		return err
+	}
+	if _, err = trans.Exec("UPDATE SentTx SET BlockNum=?, Status=?, CheckedAt=? WHERE TrxID=?",
+		tx.BlockNum, tx.Status, tx.CheckedAt, tx.TrxID); nil != err {
+		trans.Rollback()
+		return err
+	}
+	return trans.Commit()
+}
+
+// Wait : 每隔 interval 调用一次 Poll，直到没有 pending 交易或 stop 关闭
+func Wait(dbmap *gorp.DbMap, nodeURL string, interval time.Duration, onSettled Settled, stop <-chan struct{}) error {
+	for {
+		pending, err := Poll(dbmap, nodeURL, onSettled)
+		if nil != err {
+			log.Warnf("confirm.Wait - Poll failed : %v, retry later", err)
+		} else if pending == 0 {
+			return nil
+		} else {
+			log.Infof("confirm.Wait - %d transactions pending", pending)
+		}
+		select {
+		case <-time.After(interval):
+		case <-stop:
+			log.Infof("confirm.Wait - stopped, pending transactions will be checked next run")
+			return nil
+		}
+	}
+}
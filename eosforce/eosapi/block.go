@@ -0,0 +1,170 @@
+package eosapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Block : 一个 block 中按执行顺序排列的 action
+type Block struct {
+	Num             uint64
+	ID              string
+	Timestamp       string
+	Producer        string
+	ScheduleVersion uint32 // 只有 get_block 取到的 block 有
+	Actions         []Action
+}
+
+// blockEpoch : block 时间从 2000-01-01 起按出块间隔一个 slot 计
+var blockEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Slot : block 时间所在的出块 slot，interval 为链的出块间隔，eosio 为 0.5 秒，EOSForce 为 3 秒。
+// 时间无法解析返回0
+func (b *Block) Slot(interval time.Duration) uint64 {
+	tm, err := time.Parse(TimeLayout, b.Timestamp)
+	if nil != err || tm.Before(blockEpoch) {
+		return 0
+	}
+	return uint64(tm.Sub(blockEpoch) / interval)
+}
+
+// respGetBlock : /v1/chain/get_block 的返回，只取用得到的字段
+type respGetBlock struct {
+	ID              string `json:"id"`
+	BlockNum        uint64 `json:"block_num"`
+	Timestamp       string `json:"timestamp"`
+	Producer        string `json:"producer"`
+	ScheduleVersion uint32 `json:"schedule_version"`
+	Transactions    []struct {
+		Status string          `json:"status"`
+		Trx    json.RawMessage `json:"trx"` // 延迟交易只有 id 字符串，普通交易是对象
+	} `json:"transactions"`
+}
+
+// packedTrx : get_block 中解开的交易
+type packedTrx struct {
+	ID          string `json:"id"`
+	Transaction struct {
+		Actions []struct {
+			Account       string          `json:"account"`
+			Name          string          `json:"name"`
+			Authorization []Authorization `json:"authorization"`
+			Data          json.RawMessage `json:"data"`
+			HexData       string          `json:"hex_data"`
+		} `json:"actions"`
+	} `json:"transaction"`
+}
+
+// respTraceBlock : /v1/trace_api/get_block 的返回
+type respTraceBlock struct {
+	ID           string `json:"id"`
+	Number       uint64 `json:"number"`
+	Timestamp    string `json:"timestamp"`
+	Producer     string `json:"producer"`
+	Transactions []struct {
+		ID      string `json:"id"`
+		Status  string `json:"status"`
+		Actions []struct {
+			GlobalSequence uint64 `json:"global_sequence"`
+			Receiver       string `json:"receiver"`
+			Account        string `json:"account"`
+			Action         string `json:"action"`
+			Authorization  []struct {
+				Account    string `json:"account"`
+				Permission string `json:"permission"`
+			} `json:"authorization"`
+			Data json.RawMessage `json:"data"`
+		} `json:"actions"`
+	} `json:"transactions"`
+}
+
+// SyntheticSeq : GetBlock 合成的 GlobalActionSeq 都带这一位，真实的 global sequence 到不了这么大。
+// 不用最高位，sqlite 的整数是有符号的
+const SyntheticSeq uint64 = 1 << 62
+
+// syntheticIdxBits : 合成的 GlobalActionSeq 中 action 序号占的位数，剩下的 42 位放块号
+const syntheticIdxBits = 20
+
+// GetBlock : 通过 /v1/chain/get_block 取一个 block 中已执行交易的顶层 action。
+// get_block 没有 inline action，也没有 global sequence，GlobalActionSeq 用 SyntheticSeq|块号<<20|序号 代替，
+// 只保证同一来源内递增，不能和 history 插件取到的记录混在一个库里。序号放不下的 block 返回错误，不会和下一块重号。
+// 延迟交易在 get_block 里只有 id，跳过。
+func GetBlock(nodeURL string, num uint64) (*Block, error) {
+	var resp respGetBlock
+	if err := PostJSON(nodeURL, "/v1/chain/get_block", fmt.Sprintf(`{"block_num_or_id": "%d"}`, num), &resp); nil != err {
+		return nil, err
+	}
+	if resp.BlockNum != num {
+		return nil, fmt.Errorf("get_block %d returned block %d", num, resp.BlockNum)
+	}
+	block := &Block{Num: resp.BlockNum, ID: resp.ID, Timestamp: resp.Timestamp, Producer: resp.Producer, ScheduleVersion: resp.ScheduleVersion}
+	for _, t := range resp.Transactions {
+		if t.Status != "executed" {
+			continue
+		}
+		var trx packedTrx
+		if err := json.Unmarshal(t.Trx, &trx); nil != err {
+			continue
+		}
+		for _, a := range trx.Transaction.Actions {
+			idx := uint64(len(block.Actions))
+			if idx >= 1<<syntheticIdxBits {
+				return nil, fmt.Errorf("get_block %d has more than %d actions, can not synthesize sequence numbers", num, idx)
+			}
+			var act Action
+			act.GlobalActionSeq = SyntheticSeq | num<<syntheticIdxBits | idx
+			act.BlockNum = num
+			act.BlockTime = resp.Timestamp
+			act.ActionTrace.TrxID = trx.ID
+			act.ActionTrace.Act.Account = a.Account
+			act.ActionTrace.Act.Name = a.Name
+			act.ActionTrace.Act.Authorization = a.Authorization
+			act.ActionTrace.Act.Data = a.Data
+			act.ActionTrace.Act.HexData = a.HexData
+			block.Actions = append(block.Actions, act)
+		}
+	}
+	return block, nil
+}
+
+// GetTraceBlock : 通过 trace_api 插件取一个 block 中的全部 action，包括 inline action。
+// 通知给其他账号的副本（receiver 不是 account）跳过，和 history 插件中合约账号看到的一致。
+// trace_api 不给出调用关系，inline action 按执行顺序展开成顶层 action，InlineTraces 总为空，
+// 靠 InlineTransfers 取 claim 分红的逻辑取不到；stake.Handler 把同一交易里紧跟在 claim 后面的
+// 系统账号转账记为它的分红，其它依赖 inline 树的处理不能用这个来源。
+func GetTraceBlock(nodeURL string, num uint64) (*Block, error) {
+	var resp respTraceBlock
+	if err := PostJSON(nodeURL, "/v1/trace_api/get_block", fmt.Sprintf(`{"block_num": %d}`, num), &resp); nil != err {
+		return nil, err
+	}
+	if resp.Number != num {
+		return nil, fmt.Errorf("trace_api get_block %d returned block %d", num, resp.Number)
+	}
+	// trace_api 的时间带 Z 后缀，去掉后和 get_block 一致，可用 TimeLayout 解析
+	block := &Block{Num: resp.Number, ID: resp.ID, Timestamp: strings.TrimSuffix(resp.Timestamp, "Z"), Producer: resp.Producer}
+	for _, t := range resp.Transactions {
+		if t.Status != "executed" {
+			continue
+		}
+		for _, a := range t.Actions {
+			if a.Receiver != a.Account {
+				continue
+			}
+			var act Action
+			act.GlobalActionSeq = a.GlobalSequence
+			act.BlockNum = num
+			act.BlockTime = block.Timestamp
+			act.ActionTrace.TrxID = t.ID
+			act.ActionTrace.Act.Account = a.Account
+			act.ActionTrace.Act.Name = a.Action
+			for _, auth := range a.Authorization {
+				act.ActionTrace.Act.Authorization = append(act.ActionTrace.Act.Authorization, Authorization{Actor: auth.Account, Permission: auth.Permission})
+			}
+			act.ActionTrace.Act.Data = a.Data
+			block.Actions = append(block.Actions, act)
+		}
+	}
+	return block, nil
+}
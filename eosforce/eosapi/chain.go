@@ -0,0 +1,103 @@
+package eosapi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RespGetInfo : /v1/chain/get_info 的返回
+type RespGetInfo struct {
+	ChainID                  string `json:"chain_id"`
+	HeadBlockNum             uint64 `json:"head_block_num"`
+	LastIrreversibleBlockNum uint64 `json:"last_irreversible_block_num"`
+	HeadBlockTime            string `json:"head_block_time"`
+	HeadBlockProducer        string `json:"head_block_producer"`
+}
+
+// HeadTime : 解析 head_block_time，失败返回零值
+func (info *RespGetInfo) HeadTime() time.Time {
+	tm, _ := time.Parse(TimeLayout, info.HeadBlockTime)
+	return tm
+}
+
+// RespGetTransaction : /v1/history/get_transaction 的返回，找不到时 ID 为空
+type RespGetTransaction struct {
+	ID  string `json:"id"`
+	Trx struct {
+		Receipt struct {
+			Status string `json:"status"`
+		} `json:"receipt"`
+	} `json:"trx"`
+	BlockNum              uint64 `json:"block_num"`
+	BlockTime             string `json:"block_time"`
+	LastIrreversibleBlock uint64 `json:"last_irreversible_block"`
+}
+
+// ProducerSchedule : 出块顺序
+type ProducerSchedule struct {
+	Version   uint32 `json:"version"`
+	Producers []struct {
+		ProducerName string `json:"producer_name"`
+	} `json:"producers"`
+}
+
+// Names : 按出块顺序排列的 BP 名字
+func (s *ProducerSchedule) Names() []string {
+	names := make([]string, 0, len(s.Producers))
+	for _, p := range s.Producers {
+		names = append(names, p.ProducerName)
+	}
+	return names
+}
+
+// RespGetProducerSchedule : /v1/chain/get_producer_schedule 的返回
+type RespGetProducerSchedule struct {
+	Active   ProducerSchedule  `json:"active"`
+	Pending  *ProducerSchedule `json:"pending"`
+	Proposed *ProducerSchedule `json:"proposed"`
+}
+
+// GetInfo : 查询链的当前状态
+func GetInfo(nodeURL string) (*RespGetInfo, error) {
+	var result RespGetInfo
+	if err := PostJSON(nodeURL, "/v1/chain/get_info", "{}", &result); nil != err {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetTransaction : 按 trx_id 查询交易，节点返回 tx_not_found 时返回 ID 为空的结果
+func GetTransaction(nodeURL, trxID string) (*RespGetTransaction, error) {
+	var result RespGetTransaction
+	err := PostJSON(nodeURL, "/v1/history/get_transaction", fmt.Sprintf(`{"id": "%s"}`, trxID), &result)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && strings.Contains(apiErr.Name, "not_found") {
+		return &result, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetProducerSchedule : 查询当前生效、待生效和提议中的出块顺序
+func GetProducerSchedule(nodeURL string) (*RespGetProducerSchedule, error) {
+	var result RespGetProducerSchedule
+	if err := PostJSON(nodeURL, "/v1/chain/get_producer_schedule", "{}", &result); nil != err {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetBlockTime : 查询一个 block 的时间，只解析 timestamp
+func GetBlockTime(nodeURL string, num uint64) (time.Time, error) {
+	var result struct {
+		Timestamp string `json:"timestamp"`
+	}
+	if err := PostJSON(nodeURL, "/v1/chain/get_block", fmt.Sprintf(`{"block_num_or_id": "%d"}`, num), &result); nil != err {
+		return time.Time{}, err
+	}
+	return time.Parse(TimeLayout, result.Timestamp)
+}
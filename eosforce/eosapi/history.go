@@ -0,0 +1,217 @@
+// Package eosapi 封装各工具共用的节点 HTTP 接口。
+package eosapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/budget"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/metrics"
+)
+
+var log = logging.New("eosapi")
+
+// TimeLayout : 节点返回的 block_time 格式
+const TimeLayout = "2006-01-02T15:04:05"
+
+// Authorization : action 的授权
+type Authorization struct {
+	Actor      string `json:"actor"`
+	Permission string `json:"permission"`
+}
+
+// Act : action 本身
+type Act struct {
+	Account       string          `json:"account"`
+	Name          string          `json:"name"`
+	Authorization []Authorization `json:"authorization"`
+	Data          json.RawMessage `json:"data"`
+	HexData       string          `json:"hex_data"`
+}
+
+// ActionReceipt : action 的执行收据，Receiver 不是合约本身时是发给 Receiver 的通知
+type ActionReceipt struct {
+	Receiver       string `json:"receiver"`
+	GlobalSequence uint64 `json:"global_sequence"`
+}
+
+// ActionTrace : action 的执行记录，history 插件返回的带有它发出的 inline action 和通知
+type ActionTrace struct {
+	Receipt      ActionReceipt `json:"receipt"`
+	Act          Act           `json:"act"`
+	TrxID        string        `json:"trx_id"`
+	InlineTraces []ActionTrace `json:"inline_traces"`
+}
+
+// Action : get_actions 返回的一条 action
+type Action struct {
+	AccountActionSeq int64       `json:"account_action_seq"`
+	GlobalActionSeq  uint64      `json:"global_action_seq"`
+	BlockNum         uint64      `json:"block_num"`
+	BlockTime        string      `json:"block_time"`
+	ActionTrace      ActionTrace `json:"action_trace"`
+}
+
+// RespGetActions : /v1/history/get_actions 的返回
+type RespGetActions struct {
+	Actions               []Action `json:"actions"`
+	LastIrreversibleBlock uint64   `json:"last_irreversible_block"`
+}
+
+// TransferData : transfer action 的 data
+type TransferData struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Quantity string `json:"quantity"`
+	Memo     string `json:"memo"`
+}
+
+// Time : 解析 block_time，失败返回零值
+func (act *Action) Time() time.Time {
+	tm, _ := time.Parse(TimeLayout, act.BlockTime)
+	return tm
+}
+
+// Transfer : 解析 transfer 的 data，不是 transfer 返回 nil
+func (act *Action) Transfer() *TransferData {
+	if act.ActionTrace.Act.Name != "transfer" {
+		return nil
+	}
+	var data TransferData
+	if err := json.Unmarshal(act.ActionTrace.Act.Data, &data); nil != err {
+		log.Warnf("Action.Transfer - data of seq %d unrecognized : %v", act.GlobalActionSeq, err)
+		return nil
+	}
+	return &data
+}
+
+// TokenTransfer : 解析 contract 合约 transfer 的 data，其它合约的同名 action 和发给 from/to 的通知副本返回 nil
+func (act *Action) TokenTransfer(contract string) *TransferData {
+	receipt, a := &act.ActionTrace.Receipt, &act.ActionTrace.Act
+	if a.Account != contract || (receipt.Receiver != "" && receipt.Receiver != a.Account) {
+		return nil
+	}
+	return act.Transfer()
+}
+
+// InlineTransfers : trace 发出的 contract 合约的全部 inline transfer，包括 inline action 再发出的。
+// 其它合约的 transfer、发给 from/to 的通知和 data 解析不了的跳过
+func (t *ActionTrace) InlineTransfers(contract string) []*TransferData {
+	var transfers []*TransferData
+	for idx := range t.InlineTraces {
+		inline := &t.InlineTraces[idx]
+		notify := inline.Receipt.Receiver != "" && inline.Receipt.Receiver != inline.Act.Account
+		if inline.Act.Account == contract && inline.Act.Name == "transfer" && !notify {
+			var data TransferData
+			if err := json.Unmarshal(inline.Act.Data, &data); nil == err {
+				transfers = append(transfers, &data)
+			}
+		}
+		transfers = append(transfers, inline.InlineTransfers(contract)...)
+	}
+	return transfers
+}
+
+// client : 访问节点用的 http client，请求超时后按可重试的错误处理
+var client = &http.Client{Timeout: time.Minute}
+
+// PostJSON : 向 nodeURL+path POST params，把返回解析到 result。
+// 每次请求前按接入点限速；Retryable 的错误按 budget.Backoff 退避后重试，最多 MaxRetries 次，
+// 节点给了 Retry-After 时至少等这么久。
+func PostJSON(nodeURL, path, params string, result interface{}) error {
+	for attempt := 0; ; attempt++ {
+		Wait(nodeURL)
+		err := postOnce(nodeURL, path, params, result)
+		if nil == err || !Retryable(err) || attempt >= MaxRetries {
+			return err
+		}
+		wait := budget.Backoff(attempt)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+			holdOff(nodeURL, httpErr.RetryAfter)
+			if httpErr.RetryAfter > wait {
+				wait = httpErr.RetryAfter
+			}
+		}
+		log.Warnf("PostJSON - %s failed %d times, retry in %v : %v", path, attempt+1, wait, err)
+		time.Sleep(wait)
+	}
+}
+
+func postOnce(nodeURL, path, params string, result interface{}) (err error) {
+	defer func(start time.Time) { metrics.ObserveRPC(path, start, err) }(time.Now())
+	resp, err := client.Post(strings.TrimRight(nodeURL, "/")+path,
+		"application/json",
+		strings.NewReader(params))
+	if nil != err {
+		log.Errorf("PostJSON - http.Post %s failed : %v", path, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		log.Errorf("PostJSON - ioutil.ReadAll failed : %v", err)
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		err = statusError(path, resp, buf)
+		log.Debugf("PostJSON - %s response : %s", path, buf)
+		return err
+	}
+	if err = json.Unmarshal(buf, result); nil != err {
+		log.Errorf("PostJSON - json.Unmarshal failed : %v", err)
+		log.Debugf("PostJSON - %s response : %s", path, buf)
+		return err
+	}
+	return nil
+}
+
+// GetActions : 查询 account 的 history，pos/offset 含义同 /v1/history/get_actions
+func GetActions(nodeURL, account string, pos, offset int64) (*RespGetActions, error) {
+	params := fmt.Sprintf(`{"account_name": "%s", "pos": "%d", "offset": "%d"}`, account, pos, offset)
+	var result RespGetActions
+	if err := PostJSON(nodeURL, "/v1/history/get_actions", params, &result); nil != err {
+		return nil, err
+	}
+	metrics.PageFetched(metrics.PageHistory)
+	return &result, nil
+}
+
+// WalkBackward : 从最新的 action 开始往前遍历 account 的 history，fn 返回 false 时停止
+func WalkBackward(nodeURL, account string, fn func(act *Action) bool) error {
+	const offset = 99
+	pos := int64(-1)
+	for {
+		respActs, err := GetActions(nodeURL, account, pos, -offset)
+		if nil != err {
+			return err
+		}
+		if len(respActs.Actions) == 0 {
+			return nil
+		}
+		minSeq := respActs.Actions[0].AccountActionSeq
+		for idx := len(respActs.Actions) - 1; idx >= 0; idx-- {
+			act := &respActs.Actions[idx]
+			if act.AccountActionSeq < minSeq {
+				minSeq = act.AccountActionSeq
+			}
+			if pos >= 0 && act.AccountActionSeq > pos {
+				continue
+			}
+			if !fn(act) {
+				return nil
+			}
+		}
+		if minSeq <= 0 {
+			return nil
+		}
+		pos = minSeq - 1
+	}
+}
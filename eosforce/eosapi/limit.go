@@ -0,0 +1,187 @@
+package eosapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MaxRetries : 可重试的错误最多重试几次，0表示不重试
+var MaxRetries = 5
+
+// HTTPError : 节点或前面的代理返回了非 2xx，且不是节点的 json 错误
+type HTTPError struct {
+	Path       string
+	StatusCode int
+	RetryAfter time.Duration // Retry-After 头，没有为0
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s : http status %d : %s", e.Path, e.StatusCode, e.Body)
+}
+
+// APIError : 节点返回的 json 错误，如 tx_not_found、eosio_assert_message_exception
+type APIError struct {
+	Path       string
+	StatusCode int
+	Code       int64  `json:"code"`
+	Name       string `json:"name"`
+	What       string `json:"what"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s : %s(%d) : %s", e.Path, e.Name, e.Code, e.What)
+}
+
+// Retryable : 限流、5xx、超时、连接被拒绝或断开可以重试。
+// 节点的 json 错误、无法解析的返回，以及协议不支持、地址错误、证书错误这类每次都一样的错误不能重试
+func Retryable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode == http.StatusRequestTimeout ||
+			httpErr.StatusCode >= 500
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return false
+	}
+	// http.Client 的错误都包在 *url.Error 里，它的 Timeout 取自里面的错误
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryAfter : 解析 Retry-After 头，秒数或 http 日期
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); nil == err && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if tm, err := http.ParseTime(value); nil == err {
+		if d := time.Until(tm); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// statusError : 把非 2xx 的返回转成 APIError 或 HTTPError
+func statusError(path string, resp *http.Response, body []byte) error {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		// 限流和网关错误，不管 body 是什么都当作临时错误
+	default:
+		var nodeErr struct {
+			Error APIError `json:"error"`
+		}
+		if nil == json.Unmarshal(body, &nodeErr) && nodeErr.Error.Name != "" {
+			nodeErr.Error.Path, nodeErr.Error.StatusCode = path, resp.StatusCode
+			return &nodeErr.Error
+		}
+	}
+	if len(body) > 200 {
+		body = body[:200]
+	}
+	return &HTTPError{Path: path, StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header.Get("Retry-After")), Body: string(body)}
+}
+
+// bucket : 一个接入点的令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+	until  time.Time // 收到 Retry-After 后，在此之前不发请求
+}
+
+var (
+	limitMu    sync.Mutex
+	limitRate  float64
+	limitBurst float64
+	buckets    = make(map[string]*bucket)
+)
+
+// SetRateLimit : 每个接入点（按 host 区分）每秒最多 perSecond 个请求，最多攒 burst 个，perSecond 为0不限制
+func SetRateLimit(perSecond float64, burst int) {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+	if burst < 1 {
+		burst = 1
+	}
+	limitRate, limitBurst = perSecond, float64(burst)
+	buckets = make(map[string]*bucket)
+}
+
+func endpointKey(nodeURL string) string {
+	if u, err := url.Parse(nodeURL); nil == err && u.Host != "" {
+		return u.Host
+	}
+	return nodeURL
+}
+
+// reserve : 取一个令牌，返回需要等多久
+func reserve(nodeURL string) time.Duration {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+	now := time.Now()
+	key := endpointKey(nodeURL)
+	b, ok := buckets[key]
+	if !ok {
+		b = &bucket{tokens: limitBurst, last: now}
+		buckets[key] = b
+	}
+	var wait time.Duration
+	if b.until.After(now) {
+		wait = b.until.Sub(now)
+	}
+	if limitRate <= 0 {
+		return wait
+	}
+	b.tokens += now.Sub(b.last).Seconds() * limitRate
+	if b.tokens > limitBurst {
+		b.tokens = limitBurst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens < 0 {
+		if d := time.Duration(-b.tokens / limitRate * float64(time.Second)); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// Wait : 按 nodeURL 的限速等到可以发下一个请求，发送交易的工具在调用 cleos 前也要调用
+func Wait(nodeURL string) {
+	if wait := reserve(nodeURL); wait > 0 {
+		log.Debugf("Wait - %s throttled for %v", endpointKey(nodeURL), wait)
+		time.Sleep(wait)
+	}
+}
+
+// holdOff : 节点要求 d 之后再来，同一接入点的其它请求一起等
+func holdOff(nodeURL string, d time.Duration) {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+	key := endpointKey(nodeURL)
+	b, ok := buckets[key]
+	if !ok {
+		b = &bucket{tokens: limitBurst, last: time.Now()}
+		buckets[key] = b
+	}
+	if until := time.Now().Add(d); until.After(b.until) {
+		b.until = until
+	}
+}
@@ -0,0 +1,73 @@
+package eosapi
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// postErr : 用真实的 http.Client 发一次请求，取它返回的错误
+func postErr(t *testing.T, target string) error {
+	resp, err := client.Post(target, "application/json", strings.NewReader("{}"))
+	if nil == err {
+		resp.Body.Close()
+		t.Fatalf("post %s should fail", target)
+	}
+	return err
+}
+
+// closedPort : 一个没有人监听的本地地址
+func closedPort(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestRetryable(t *testing.T) {
+	wrap := func(err error) error { return &url.Error{Op: "Post", URL: "http://node/v1/chain/get_info", Err: err} }
+	sysErr := func(errno syscall.Errno) error {
+		return wrap(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", errno)})
+	}
+
+	for _, c := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"429", &HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{"408", &HTTPError{StatusCode: http.StatusRequestTimeout}, true},
+		{"503", &HTTPError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"500 wrapped", fmt.Errorf("get_info : %w", &HTTPError{StatusCode: http.StatusInternalServerError}), true},
+		{"404", &HTTPError{StatusCode: http.StatusNotFound}, false},
+		{"node json error", &APIError{StatusCode: http.StatusInternalServerError, Name: "eosio_assert_message_exception"}, false},
+		{"timeout", wrap(context.DeadlineExceeded), true},
+		{"connection refused", postErr(t, "http://"+closedPort(t)), true},
+		{"connection reset", sysErr(syscall.ECONNRESET), true},
+		{"server closed connection", wrap(io.EOF), true},
+		{"truncated body", io.ErrUnexpectedEOF, true},
+		{"unsupported scheme", postErr(t, "ftp://127.0.0.1/v1/chain/get_info"), false},
+		{"bad url", postErr(t, "http://[::1/v1/chain/get_info"), false},
+		{"unknown certificate authority", wrap(x509.UnknownAuthorityError{}), false},
+		{"bad hostname", wrap(x509.HostnameError{Host: "node"}), false},
+		{"bad json", &json.SyntaxError{Offset: 1}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Retryable(c.err); got != c.want {
+				t.Errorf("Retryable(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package eosapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gpmn/eosutils/eosforce/metrics"
+)
+
+func charToVal(ch byte) uint8 {
+	if ch >= 'a' && ch <= 'z' {
+		return ch - 'a' + 6
+	}
+	if ch >= '1' && ch <= '5' {
+		return ch - '1' + 1
+	}
+	return 0
+}
+
+// StrToName : 把账号名编码成 uint64，用作 get_table_rows 的 lower_bound
+func StrToName(strName string) uint64 {
+	name := uint64(0)
+	idx := 0
+	for ; idx < len(strName) && idx < 12; idx++ {
+		name |= uint64(charToVal(strName[idx])&0x1f) << uint(64-5*(idx+1))
+	}
+	if idx == 12 && len(strName) > 12 {
+		name |= uint64(charToVal(strName[12])) & 0xf
+	}
+	return name
+}
+
+// NameToStr : StrToName 的逆操作，用于解码二进制 action 中的账号名
+func NameToStr(name uint64) string {
+	const charmap = ".12345abcdefghijklmnopqrstuvwxyz"
+	str := make([]byte, 13)
+	tmp := name
+	for i := 0; i <= 12; i++ {
+		var c byte
+		if i == 0 {
+			c = charmap[tmp&0x0f]
+			tmp >>= 4
+		} else {
+			c = charmap[tmp&0x1f]
+			tmp >>= 5
+		}
+		str[12-i] = c
+	}
+	return strings.TrimRight(string(str), ".")
+}
+
+// TableQuery : /v1/chain/get_table_rows 的参数
+type TableQuery struct {
+	Code       string `json:"code"`
+	Scope      string `json:"scope"`
+	Table      string `json:"table"`
+	LowerBound string `json:"lower_bound,omitempty"`
+	UpperBound string `json:"upper_bound,omitempty"`
+	Limit      int    `json:"limit"`
+}
+
+// RespGetTableRows : /v1/chain/get_table_rows 的返回
+type RespGetTableRows struct {
+	Rows []json.RawMessage `json:"rows"`
+	More bool              `json:"more"`
+}
+
+// GetTableRows : 查询合约表的一页
+func GetTableRows(nodeURL string, query *TableQuery) (*RespGetTableRows, error) {
+	params, err := json.Marshal(struct {
+		JSON bool `json:"json"`
+		*TableQuery
+	}{true, query})
+	if nil != err {
+		return nil, fmt.Errorf("json.Marshal failed : %v", err)
+	}
+	var result RespGetTableRows
+	if err = PostJSON(nodeURL, "/v1/chain/get_table_rows", string(params), &result); nil != err {
+		return nil, err
+	}
+	metrics.PageFetched(metrics.PageTable)
+	return &result, nil
+}
+
+// 常见表的主键字段，按顺序尝试
+var rowKeyFields = []string{"id", "name", "owner", "account", "key", "primary_key"}
+
+// RowKey : 猜测一行的主键，返回可用作 lower_bound 的字符串
+func RowKey(row json.RawMessage) (string, error) {
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(row))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); nil != err {
+		return "", err
+	}
+	for _, name := range rowKeyFields {
+		switch v := fields[name].(type) {
+		case json.Number:
+			return v.String(), nil
+		case string:
+			if _, err := strconv.ParseUint(v, 10, 64); nil == err {
+				return v, nil
+			}
+			return strconv.FormatUint(StrToName(v), 10), nil
+		}
+	}
+	return "", fmt.Errorf("no key field in row %s", row)
+}
+
+// ParseAsset : 解析 "12.3400 EOS" 形式的资产，返回按 precision 位小数计的最小单位数量和币种
+func ParseAsset(asset string, precision int) (amount uint64, symbol string, err error) {
+	ss := strings.Split(strings.TrimSpace(asset), " ")
+	if len(ss) != 2 {
+		return 0, "", fmt.Errorf("asset '%s' invalid", asset)
+	}
+	parts := strings.SplitN(ss[0], ".", 2)
+	frac := ""
+	if len(parts) == 2 {
+		frac = parts[1]
+	}
+	if len(frac) > precision {
+		return 0, "", fmt.Errorf("asset '%s' has more than %d decimals", asset, precision)
+	}
+	frac += strings.Repeat("0", precision-len(frac))
+	amount, err = strconv.ParseUint(parts[0]+frac, 10, 64)
+	if nil != err {
+		return 0, "", fmt.Errorf("asset '%s' invalid : %v", asset, err)
+	}
+	return amount, ss[1], nil
+}
+
+// FormatAsset : ParseAsset 的逆操作，如 FormatAsset(123400, 4, "EOS") 为 "12.3400 EOS"
+func FormatAsset(amount uint64, precision int, symbol string) string {
+	if precision <= 0 {
+		return fmt.Sprintf("%d %s", amount, symbol)
+	}
+	unit := uint64(1)
+	for i := 0; i < precision; i++ {
+		unit *= 10
+	}
+	return fmt.Sprintf("%d.%0*d %s", amount/unit, precision, amount%unit, symbol)
+}
+
+// ToUnits : 把浮点数额换算成 precision 位小数的最小单位
+func ToUnits(v float64, precision int) uint64 {
+	if v <= 0 {
+		return 0
+	}
+	return uint64(v*math.Pow10(precision) + 0.5)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/gpmn/eosutils/eosforce/accounts"
+)
+
+func runAccounts(args []string) error {
+	fs, cf := newFlagSet("accounts")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if err = e.verifyChain(); nil != err {
+		return err
+	}
+	dbmap, err := e.openDB(accounts.AddTables)
+	if nil != err {
+		return err
+	}
+	if err = accounts.FetchAll(dbmap, e.nodeURL, e.chain.Dialect, e.chain.Precision); nil != err {
+		return fail(exitRPC, "accounts.FetchAll failed : %v", err)
+	}
+	log.Infof("accounts - done")
+	return nil
+}
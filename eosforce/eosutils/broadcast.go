@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/accounts"
+	"github.com/gpmn/eosutils/eosforce/budget"
+	"github.com/gpmn/eosutils/eosforce/confirm"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/optout"
+)
+
+const (
+	dbAdvertise   = `免费赚10万EOSC，微信搜索小程序“链圈挖钻助手”创建领地赚EOSC，由链圈超级节点打造DAPP小程序版本。`
+	snapAdvertise = `EosForce is the first DPOS chain based on EOS that voter can share revenue with BP,It's far more fair than original one.It comply with genesis snapshot.So we are waiting for U come back eargly @ eosforce.io,and please vote imlianquan eosshuimu miduoduo.`
+)
+
+// advTask : 一次广告发送的参数
+type advTask struct {
+	from     string
+	adv      string
+	quantity string
+	amount   uint64 // quantity 的最小单位数
+}
+
+func runBroadcast(args []string) error {
+	fs, cf := newFlagSet("broadcast")
+	valve := fs.Float64("valve", 100, "只向持仓大于valve的账号发送广告.")
+	adv := fs.String("adv", "", "自定义广告词，为空则用内置广告词.")
+	quantity := fs.String("quantity", "", "每条广告附带的金额，为空时db模式为0，快照模式为最小单位1.")
+	optoutKw := fs.String("optout_kw", optout.DefaultKeywords, "退订关键字，逗号分隔。向from转账且备注含关键字的账号不再发送。")
+	scanOnly := fs.Bool("scan_only", false, "只扫描退订转账，不发送广告。")
+	snapPath := fs.String("snap", "", "创世快照csv文件路径，为空则向db的AccountInfo发送.")
+	hisPath := fs.String("his", "", "快照模式的进度文件路径.")
+	sf := addSendFlags(fs, 10, 600)
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if *sf.from == "" {
+		fs.Usage()
+		return fail(exitUsage, "from param missed")
+	}
+	if *snapPath != "" && *hisPath == "" {
+		fs.Usage()
+		return fail(exitUsage, "his param missed")
+	}
+	task := &advTask{from: *sf.from, adv: *adv, quantity: *quantity}
+	if task.adv == "" {
+		task.adv = dbAdvertise
+		if *snapPath != "" {
+			task.adv = snapAdvertise
+		}
+	}
+	if task.quantity == "" {
+		task.quantity = e.asset(0)
+		if *snapPath != "" {
+			task.quantity = e.asset(1)
+		}
+	}
+	if task.amount, _, err = eosapi.ParseAsset(task.quantity, e.chain.Precision); nil != err {
+		fs.Usage()
+		return fail(exitUsage, "%v", err)
+	}
+
+	if err = e.verifyChain(); nil != err {
+		return err
+	}
+	dbmap, err := e.openDB(accounts.AddTables, optout.AddTables, confirm.AddTables)
+	if nil != err {
+		return err
+	}
+
+	added, err := optout.Scan(dbmap, e.nodeURL, e.chain.Token, task.from, optout.ParseKeywords(*optoutKw))
+	if nil != err {
+		return fail(exitRPC, "optout.Scan failed : %v", err)
+	}
+	log.Infof("broadcast - %d new opt-out accounts", added)
+	if *scanOnly {
+		return nil
+	}
+
+	bgt := sf.budget(e.chain.Precision)
+	defer func() {
+		amount, fees := bgt.Spent()
+		log.Infof("broadcast - spent %s + fee %s", e.asset(amount), e.asset(fees))
+	}()
+	stop := newStopper()
+	stop.watchSignals()
+
+	if *snapPath != "" {
+		err = broadcastSnapshot(dbmap, newWallet(e), task, *snapPath, *hisPath, *valve, bgt, stop)
+	} else {
+		err = broadcastDB(dbmap, newWallet(e), task, eosapi.ToUnits(*valve, e.chain.Precision), bgt, stop)
+	}
+	if nil != err {
+		return err
+	}
+	if err = sf.waitConfirmed(dbmap, e, stop); nil != err {
+		return err
+	}
+	if stop.code != 0 {
+		return fail(stop.code, "broadcast stopped")
+	}
+	return nil
+}
+
+// saveNotified : 在一个事务里标记已通知并开始跟踪交易
+func saveNotified(dbmap *gorp.DbMap, account string, result *transferResult) error {
+	trans, err := dbmap.Begin()
+	if nil != err {
+		return err
+	}
+	if result.TransactionID != "" {
+		if err = confirm.Track(trans, "adv", account, result.TransactionID, result.Processed.BlockNum, result.Expiration); nil != err {
+			trans.Rollback()
+			return err
+		}
+	}
+	if _, err = trans.Exec("UPDATE AccountInfo SET Notified=TRUE WHERE Account=?", account); nil != err {
+		trans.Rollback()
+		return err
+	}
+	return trans.Commit()
+}
+
+// sendRoutine : 逐个发送 accChan 中的账号，accChan 关闭或收到 stop 时返回
+func sendRoutine(w *wallet, task *advTask, accChan chan string, wg *sync.WaitGroup, dbmap *gorp.DbMap, bgt *budget.Budget, stop *stopper) {
+	defer wg.Done()
+	for account := range accChan {
+		select {
+		case <-stop.ch:
+			return
+		default:
+		}
+		suppressed, err := optout.IsSuppressed(dbmap, account)
+		if nil != err {
+			log.Errorf("sendRoutine - optout.IsSuppressed(%s) failed : %v", account, err)
+			stop.stop(exitDB)
+			return
+		}
+		if suppressed {
+			log.Debugf("sendRoutine - %s opted out, skip", account)
+			continue
+		}
+		var retry int
+		var result *transferResult
+		for retry = 0; retry < 10; retry++ {
+			if err = bgt.Spend(task.amount, stop.ch); nil != err {
+				log.Warnf("sendRoutine - budget.Spend : %v, stop sending", err)
+				stop.stop(exitSend)
+				return
+			}
+			result, err = w.transfer(task.from, account, task.quantity, task.adv)
+			if nil == err {
+				break
+			}
+			log.Warnf("sendMessage %s -> %s failed %d times : %v", task.from, account, retry+1, err)
+			if !retryableSend(err) {
+				break
+			}
+			select {
+			case <-time.After(budget.Backoff(retry)):
+			case <-stop.ch:
+				return
+			}
+		}
+		if errors.Is(err, errSendUnknown) {
+			// 结果未知，记为已通知，避免下次运行重复发送
+			if err2 := saveNotified(dbmap, account, &transferResult{}); nil != err2 {
+				log.Errorf("sendRoutine - saveNotified(%s) failed : %v", account, err2)
+			}
+		}
+		if nil != err {
+			log.Errorf("sendMessage to %s failed, stop sending : %v", account, err)
+			stop.stop(exitSend)
+			return
+		}
+		if err = saveNotified(dbmap, account, result); nil != err {
+			log.Errorf("sendRoutine - saveNotified(%s) failed : %v", account, err)
+			stop.stop(exitDB)
+			return
+		}
+	}
+}
+
+// broadcastDB : 向 AccountInfo 中持仓不少于 valve 且未通知过的账号发送广告
+func broadcastDB(dbmap *gorp.DbMap, w *wallet, task *advTask, valve uint64, bgt *budget.Budget, stop *stopper) error {
+	var rows []accounts.AccountInfo
+	accChan := make(chan string, 40)
+
+	var wg sync.WaitGroup
+	wg.Add(40)
+	for idx := 0; idx < 40; idx++ {
+		go sendRoutine(w, task, accChan, &wg, dbmap, bgt, stop)
+	}
+	if _, err := dbmap.Select(&rows, "SELECT * FROM AccountInfo WHERE Amount>=? AND Notified=FALSE AND Account NOT IN (SELECT Account FROM OptOut)", valve); nil != err {
+		stop.stop(exitDB)
+		close(accChan)
+		wg.Wait()
+		return fail(exitDB, "select AccountInfo failed : %v", err)
+	}
+feed:
+	for _, account := range rows {
+		select {
+		case accChan <- account.Account:
+		case <-stop.ch:
+			break feed
+		}
+	}
+	close(accChan)
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/accounts"
+	"github.com/gpmn/eosutils/eosforce/confirm"
+	"github.com/gpmn/eosutils/eosforce/payout"
+)
+
+// runConfirm : 只确认之前发送的交易，不发送新的
+func runConfirm(args []string) error {
+	fs, cf := newFlagSet("confirm")
+	interval := fs.Duration("confirm_interval", 10*time.Second, "查询交易状态的间隔.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	dbmap, err := e.openDB(accounts.AddTables, payout.AddTables, confirm.AddTables)
+	if nil != err {
+		return err
+	}
+	stop := newStopper()
+	stop.watchSignals()
+	if err = confirm.Wait(dbmap, e.nodeURL, *interval, onSettled, stop.ch); nil != err {
+		return fail(exitRPC, "confirm.Wait failed : %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/export"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+var tableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 各过滤条件按顺序尝试的列名，表中都没有时不能使用该条件
+var (
+	bpColumns     = []string{"BPName", "Producer"}
+	timeColumns   = []string{"BlockTime", "UpdatedAt", "CreatedAt", "SentAt"}
+	amountColumns = []string{"Amount"}
+)
+
+// findColumn : 表中第一个名字在 names 里的列，不区分大小写（PostgreSQL 的列名是小写）
+func findColumn(cols []export.Column, names []string) string {
+	for _, name := range names {
+		for _, col := range cols {
+			if strings.EqualFold(col.Name, name) {
+				return col.Name
+			}
+		}
+	}
+	return ""
+}
+
+// runExport : 把 db 中的一张表按条件导出成 csv/jsonl/parquet
+func runExport(args []string) error {
+	fs, cf := newFlagSet("export")
+	table := fs.String("table", "", "表名，如 VoteInfo、AccountInfo，不能为空.")
+	format := fs.String("format", export.FormatCSV, "输出格式 csv、jsonl 或 parquet.")
+	out := fs.String("out", "", "输出文件，为空输出到标准输出.")
+	bp := fs.String("bp", "", "只导出这个BP的行，表中要有 BPName 或 Producer 列.")
+	beginStr := fs.String("begin_time", "", "只导出不早于begin_time的行，如 2018-06-01 00:00:00，表中要有 BlockTime 或 UpdatedAt 等时间列.")
+	endStr := fs.String("end_time", "", "只导出不晚于end_time的行.")
+	minBalance := fs.Float64("min_balance", 0, "只导出 Amount 不少于多少核心币的行，0表示不限制.")
+	maxBalance := fs.Float64("max_balance", 0, "只导出 Amount 不多于多少核心币的行，0表示不限制.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if !tableNameRe.MatchString(*table) {
+		fs.Usage()
+		return fail(exitUsage, "table '%s' invalid", *table)
+	}
+	if *format != export.FormatCSV && *format != export.FormatJSONL && *format != export.FormatParquet {
+		fs.Usage()
+		return fail(exitUsage, "format '%s' invalid, should be csv, jsonl or parquet", *format)
+	}
+
+	dbmap, err := e.openDB()
+	if nil != err {
+		return err
+	}
+	cols, err := export.Columns(dbmap.Db, *table)
+	if nil != err {
+		return fail(exitDB, "read columns of %s failed : %v", *table, err)
+	}
+
+	var where []string
+	var params []interface{}
+	// filter : 按条件追加 WHERE，表中没有可用的列时报错
+	filter := func(flagName string, names []string, op string, value interface{}) error {
+		col := findColumn(cols, names)
+		if col == "" {
+			return fail(exitUsage, "%s can not filter table %s, it has none of %s", flagName, *table, strings.Join(names, ", "))
+		}
+		where = append(where, col+op+"?")
+		params = append(params, value)
+		return nil
+	}
+	if *bp != "" {
+		if err = filter("bp", bpColumns, "=", *bp); nil != err {
+			return err
+		}
+	}
+	for _, tf := range []struct {
+		name, value, op string
+	}{{"begin_time", *beginStr, ">="}, {"end_time", *endStr, "<="}} {
+		if tf.value == "" {
+			continue
+		}
+		tm, err := time.Parse(voters.TimeLayout, tf.value)
+		if nil != err {
+			fs.Usage()
+			return fail(exitUsage, "%s '%s' invalid, should be like '%s'", tf.name, tf.value, voters.TimeLayout)
+		}
+		if err = filter(tf.name, timeColumns, tf.op, tm); nil != err {
+			return err
+		}
+	}
+	if *minBalance > 0 {
+		if err = filter("min_balance", amountColumns, ">=", eosapi.ToUnits(*minBalance, e.chain.Precision)); nil != err {
+			return err
+		}
+	}
+	if *maxBalance > 0 {
+		if err = filter("max_balance", amountColumns, "<=", eosapi.ToUnits(*maxBalance, e.chain.Precision)); nil != err {
+			return err
+		}
+	}
+
+	query := "SELECT * FROM " + *table
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if *out != "" {
+		if file, err = os.Create(*out); nil != err {
+			return fail(exitFailed, "create %s failed : %v", *out, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	count, err := export.Query(dbmap.Db, *format, w, query, params...)
+	if nil != err {
+		return fail(exitDB, "export %s failed after %d rows : %v", *table, count, err)
+	}
+	if file != nil {
+		if err = file.Close(); nil != err {
+			return fail(exitFailed, "close %s failed : %v", *out, err)
+		}
+	}
+	log.Infof("exported %d rows of %s as %s", count, *table, *format)
+	return nil
+}
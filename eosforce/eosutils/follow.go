@@ -0,0 +1,74 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/config"
+	"github.com/gpmn/eosutils/eosforce/follow"
+	"github.com/gpmn/eosutils/eosforce/stake"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+// runFollow : 不依赖 history 插件，逐块跟随链并保存投票和指定的 action
+func runFollow(args []string) error {
+	fs, cf := newFlagSet("follow")
+	start := fs.Uint64("start", 1, "没有断点时从哪个block开始.")
+	name := fs.String("name", "default", "断点名，同一个db里的多个follow用不同的名字.")
+	bp := fs.String("bp", "", "把投给这个BP的投票写入VoteInfo，为空则不解析投票.")
+	actions := fs.String("actions", "", "原样记录到ActionLog的action，逗号分隔，如 eosio.token:transfer,eosio:* .")
+	traces := fs.Bool("traces", false, "用 trace_api 插件取block，包含inline action。不用时投票的SeqNum是合成的，不能和voters、ship写入的记录放在一个db.")
+	interval := fs.Duration("interval", 3*time.Second, "追上不可逆块后的查询间隔.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	actionLog := follow.NewActionLog(*actions)
+	if *bp == "" && actionLog.Empty() {
+		fs.Usage()
+		return fail(exitUsage, "nothing to follow, set bp or actions")
+	}
+	if err = e.verifyChain(); nil != err {
+		return err
+	}
+	dbmap, err := e.openDB(follow.AddTables, follow.AddActionTables, voters.AddTables, stake.AddTables)
+	if nil != err {
+		return err
+	}
+
+	f := &follow.Follower{
+		Pipeline: follow.Pipeline{Name: *name, DB: dbmap},
+		NodeURL:  e.nodeURL,
+		Start:    *start,
+		Traces:   *traces,
+		Interval: *interval,
+	}
+	if *bp != "" {
+		last, err := f.LastBlock()
+		if nil != err {
+			return fail(exitDB, "read checkpoint %s failed : %v", *name, err)
+		}
+		if err = voters.CheckKeys(dbmap, !*traces); nil != err {
+			return fail(exitDB, "follow %s : %v", *name, err)
+		}
+		// 从断点续跑时内存中的投票人状态已丢失，只有第一次从头跑才能完全靠回溯累加
+		fromGenesis := last == 0 && *start <= 1
+		f.Handlers = append(f.Handlers, voters.NewHandler(e.nodeURL, *bp, e.chain.Dialect, e.chain.CoreSymbol, e.chain.Precision, fromGenesis))
+		if e.chain.Dialect != config.DialectEOSIO {
+			f.Handlers = append(f.Handlers, &stake.Handler{BP: *bp, Token: e.chain.Token, Symbol: e.chain.CoreSymbol, Precision: e.chain.Precision})
+		}
+	}
+	if !actionLog.Empty() {
+		f.Handlers = append(f.Handlers, actionLog)
+	}
+
+	stop := newStopper()
+	stop.watchSignals()
+	if err = f.Run(stop.ch); nil != err {
+		return fail(exitDB, "follow %s failed : %v", *name, err)
+	}
+	if stop.code != 0 {
+		return fail(stop.code, "follow stopped")
+	}
+	return nil
+}
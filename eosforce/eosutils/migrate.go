@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+// runMigrate : 把 db 升级到当前版本，默认先备份
+func runMigrate(args []string) error {
+	fs, cf := newFlagSet("migrate")
+	backup := fs.Bool("backup", true, "升级前用 VACUUM INTO 备份到 <db>.bak-v<版本>-<时间> ，postgres 不支持，需先自行 pg_dump .")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	from, to, backupPath, err := store.Migrate(e.dbPath, *backup)
+	db := store.Redact(e.dbPath)
+	if nil != err {
+		if backupPath != "" {
+			return fail(exitDB, "migrate %s from %d stopped at %d : %v, backup is %s", db, from, to, err, backupPath)
+		}
+		return fail(exitDB, "migrate %s failed : %v", db, err)
+	}
+	if from == to {
+		fmt.Printf("%s is already at schema version %d\n", db, to)
+		return nil
+	}
+	fmt.Printf("%s migrated from schema version %d to %d\n", db, from, to)
+	if backupPath != "" {
+		fmt.Printf("backup : %s\n", backupPath)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/follow"
+	"github.com/gpmn/eosutils/eosforce/monitor"
+	"github.com/gpmn/eosutils/eosforce/webhook"
+)
+
+// runMonitor : 跟随 block 头统计出块，关注的 BP 漏块或进出出块顺序时报警
+func runMonitor(args []string) error {
+	fs, cf := newFlagSet("monitor")
+	bp := fs.String("bp", "", "关注的BP，不能为空.")
+	url := fs.String("url", "", "报警webhook地址，为空则用配置的 webhook.url ，都为空只打印日志.")
+	secret := fs.String("secret", "", "HMAC签名密钥，为空则用配置的 webhook.secret .")
+	start := fs.Uint64("start", 0, "没有断点时从哪个block开始，0表示从当前不可逆块开始.")
+	name := fs.String("name", "monitor", "断点名.")
+	interval := fs.Duration("interval", time.Second, "追上不可逆块后的查询间隔，也是投递webhook的间隔.")
+	maxAttempts := fs.Int("max_attempts", 10, "每个报警最多投递几次.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if *bp == "" {
+		fs.Usage()
+		return fail(exitUsage, "missing bp param")
+	}
+	if *url == "" {
+		*url = e.cfg.Webhook.URL
+	}
+	if *secret == "" {
+		*secret = e.cfg.Webhook.Secret
+	}
+	if err = e.verifyChain(); nil != err {
+		return err
+	}
+	dbmap, err := e.openDB(follow.AddTables, monitor.AddTables, webhook.AddTables)
+	if nil != err {
+		return err
+	}
+
+	m := &monitor.Monitor{NodeURL: e.nodeURL, BP: *bp, Interval: e.chain.BlockInterval(), Repetitions: e.chain.ProducerRepetitions}
+	if *url != "" {
+		m.OnAlert = func(exec gorp.SqlExecutor, alert *monitor.Alert) error {
+			return webhook.Push(exec, *url, alert.Kind, alert, nil)
+		}
+	}
+	f := &follow.Follower{
+		Pipeline: follow.Pipeline{Name: *name, DB: dbmap, Handlers: []follow.Handler{m}},
+		NodeURL:  e.nodeURL,
+		Start:    *start,
+		Interval: *interval,
+	}
+	last, err := f.LastBlock()
+	if nil != err {
+		return fail(exitDB, "read checkpoint %s failed : %v", *name, err)
+	}
+	if last == 0 && f.Start == 0 {
+		info, err := eosapi.GetInfo(e.nodeURL)
+		if nil != err {
+			return fail(exitRPC, "get_info failed : %v", err)
+		}
+		f.Start = info.LastIrreversibleBlockNum
+	}
+
+	stop := newStopper()
+	stop.watchSignals()
+	senderDone := make(chan error, 1)
+	if *url != "" {
+		sender := &webhook.Sender{Secret: *secret, MaxAttempts: *maxAttempts}
+		go func() {
+			senderDone <- sender.Run(dbmap, *interval, stop.ch)
+		}()
+	} else {
+		senderDone <- nil
+	}
+
+	err = f.Run(stop.ch)
+	if nil != err {
+		stop.stop(exitDB)
+	}
+	if serr := <-senderDone; nil != serr && nil == err {
+		return fail(exitDB, "webhook delivery failed : %v", serr)
+	}
+	if nil != err {
+		return fail(exitDB, "monitor %s failed : %v", *name, err)
+	}
+	if stop.code != 0 {
+		return fail(stop.code, "monitor stopped")
+	}
+	return nil
+}
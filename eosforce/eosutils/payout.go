@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/budget"
+	"github.com/gpmn/eosutils/eosforce/confirm"
+	"github.com/gpmn/eosutils/eosforce/payout"
+)
+
+func runPayout(args []string) error {
+	fs, cf := newFlagSet("payout")
+	maxAttempts := fs.Int("max_attempts", 3, "每笔付款最多尝试次数.")
+	sf := addSendFlags(fs, 0, 600)
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if *sf.from == "" {
+		fs.Usage()
+		return fail(exitUsage, "from param missed")
+	}
+	if *sf.maxAmount <= 0 {
+		fs.Usage()
+		return fail(exitUsage, "max_amount must be set for payout")
+	}
+
+	if err = e.verifyChain(); nil != err {
+		return err
+	}
+	dbmap, err := e.openDB(payout.AddTables, confirm.AddTables)
+	if nil != err {
+		return err
+	}
+
+	bgt := sf.budget(e.chain.Precision)
+	defer func() {
+		amount, fees := bgt.Spent()
+		log.Infof("payout - spent %s + fee %s", e.asset(amount), e.asset(fees))
+	}()
+	stop := newStopper()
+	stop.watchSignals()
+
+	err = payAll(dbmap, newWallet(e), *sf.from, *maxAttempts, bgt, stop.ch)
+	if err == budget.ErrStopped {
+		return fail(stop.code, "payout stopped")
+	}
+	if nil != err {
+		return fail(exitSend, "payAll failed : %v", err)
+	}
+	if err = sf.waitConfirmed(dbmap, e, stop); nil != err {
+		return err
+	}
+	if stop.code != 0 {
+		return fail(stop.code, "payout stopped")
+	}
+	return nil
+}
+
+// unsettled : 状态不确定、重发前必须查链上记录的行
+func unsettled(p *payout.Payout) bool {
+	return p.Status == payout.StatusSending || p.Status == payout.StatusFailed || p.Status == payout.StatusExpired
+}
+
+// settleFromChain : 对状态不确定的行，先到链上找对应转账，找到的直接记为已发送
+func settleFromChain(dbmap *gorp.DbMap, w *wallet, from string, rows []*payout.Payout) error {
+	var since time.Time
+	for _, p := range rows {
+		if !unsettled(p) {
+			continue
+		}
+		if since.IsZero() || p.UpdatedAt.Before(since) {
+			since = p.UpdatedAt
+		}
+	}
+	if since.IsZero() {
+		return nil
+	}
+
+	receipts, err := payout.FindOnChain(w.nodeURL, w.chain.Token, from, since.Add(-10*time.Minute))
+	if nil != err {
+		log.Errorf("settleFromChain - payout.FindOnChain failed : %v", err)
+		return err
+	}
+	// 已经记在其他行上的转账不能再用，每笔转账最多结算一行
+	var known []string
+	if _, err = dbmap.Select(&known, "SELECT TrxID FROM Payout WHERE Status IN (?,?) AND TrxID<>''",
+		payout.StatusSent, payout.StatusIrreversible); nil != err {
+		log.Errorf("settleFromChain - select TrxID failed : %v", err)
+		return err
+	}
+	used := make(map[string]bool, len(known))
+	for _, trxID := range known {
+		used[trxID] = true
+	}
+
+	for _, p := range rows {
+		if !unsettled(p) {
+			continue
+		}
+		var receipt payout.Receipt
+		for _, r := range receipts[payout.Key(p.Account, w.asset(p.Amount), p.Memo)] {
+			if !used[r.TrxID] {
+				receipt = r
+				break
+			}
+		}
+		if receipt.TrxID == "" {
+			continue
+		}
+		used[receipt.TrxID] = true
+		log.Infof("settleFromChain - payout %d to %s found on chain, trx %s", p.ID, p.Account, receipt.TrxID)
+		p.Status, p.TrxID, p.BlockNum = payout.StatusSent, receipt.TrxID, receipt.BlockNum
+		if err = saveSent(dbmap, p, time.Time{}); nil != err {
+			log.Errorf("settleFromChain - saveSent(%d) failed : %v", p.ID, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// saveSent : 在一个事务里更新 Payout 行并开始跟踪交易，链上已找到的交易 expiration 传零值
+func saveSent(dbmap *gorp.DbMap, p *payout.Payout, expiration time.Time) error {
+	trans, err := dbmap.Begin()
+	if nil != err {
+		return err
+	}
+	if err = payout.SetStatus(trans, p); nil != err {
+		trans.Rollback()
+		return err
+	}
+	if p.TrxID != "" {
+		if err = confirm.Track(trans, "payout", strconv.FormatInt(p.ID, 10), p.TrxID, p.BlockNum, expiration); nil != err {
+			trans.Rollback()
+			return err
+		}
+	}
+	return trans.Commit()
+}
+
+// payAll : 逐行支付 Payout 表中未完成的付款。
+// 每行发送前先记为 sending，发送失败的行留到下次运行，在链上确认没有到账后再重发。
+// 收到 stop 时把当前这笔处理完再返回。
+func payAll(dbmap *gorp.DbMap, w *wallet, from string, maxAttempts int, bgt *budget.Budget, stop <-chan struct{}) error {
+	var rows []*payout.Payout
+	if _, err := dbmap.Select(&rows, "SELECT * FROM Payout WHERE Status NOT IN (?,?) ORDER BY ID",
+		payout.StatusSent, payout.StatusIrreversible); nil != err {
+		log.Errorf("payAll - select Payout failed : %v", err)
+		return err
+	}
+	if err := settleFromChain(dbmap, w, from, rows); nil != err {
+		return err
+	}
+
+	var sent, skipped, failed int
+	for _, p := range rows {
+		if p.Status == payout.StatusSent || p.Status == payout.StatusIrreversible {
+			continue
+		}
+		select {
+		case <-stop:
+			log.Warnf("payAll - stopped, sent %d, failed %d, skipped %d", sent, failed, skipped)
+			return budget.ErrStopped
+		default:
+		}
+		if p.Attempts >= maxAttempts {
+			log.Warnf("payAll - payout %d to %s tried %d times, skip", p.ID, p.Account, p.Attempts)
+			skipped++
+			continue
+		}
+
+		if err := bgt.Spend(p.Amount, stop); nil != err {
+			log.Warnf("payAll - budget.Spend(%s) for payout %d : %v, stop paying, sent %d, failed %d, skipped %d",
+				w.asset(p.Amount), p.ID, err, sent, failed, skipped)
+			return err
+		}
+
+		p.Status = payout.StatusSending
+		p.Attempts++
+		if err := payout.SetStatus(dbmap, p); nil != err {
+			log.Errorf("payAll - payout.SetStatus(%d) failed : %v", p.ID, err)
+			return err
+		}
+
+		quantity := w.asset(p.Amount)
+		var expiration time.Time
+		result, err := w.transfer(from, p.Account, quantity, p.Memo)
+		if errors.Is(err, errSendUnknown) {
+			// 结果未知，保持 sending，下次运行先查链上记录
+			failed++
+		} else if nil != err {
+			p.Status = payout.StatusFailed
+			failed++
+		} else {
+			p.Status, p.TrxID, p.BlockNum = payout.StatusSent, result.TransactionID, result.Processed.BlockNum
+			expiration = result.Expiration
+			sent++
+			log.Infof("payAll - paid %s to %s, trx %s @ block %d", quantity, p.Account, p.TrxID, p.BlockNum)
+		}
+		if err = saveSent(dbmap, p, expiration); nil != err {
+			log.Errorf("payAll - saveSent(%d) failed : %v", p.ID, err)
+			return err
+		}
+	}
+
+	log.Infof("payAll - sent %d, failed %d, skipped %d", sent, failed, skipped)
+	if failed > 0 {
+		return fmt.Errorf("%d payouts failed, rerun to retry after on-chain check", failed)
+	}
+	return nil
+}
+
+// onSettled : 交易确认结果写回 AccountInfo / Payout，过期的广告重新排队，
+// 过期的付款记为 expired，下次 payout 先查链上记录再决定是否重发
+func onSettled(exec gorp.SqlExecutor, tx *confirm.Tx) error {
+	switch tx.Kind {
+	case "adv":
+		if tx.Status == confirm.StatusIrreversible {
+			return nil
+		}
+		_, err := exec.Exec("UPDATE AccountInfo SET Notified=FALSE WHERE Account=?", tx.Ref)
+		return err
+	case "payout":
+		status := payout.StatusIrreversible
+		switch tx.Status {
+		case confirm.StatusExpired:
+			status = payout.StatusExpired
+		case confirm.StatusFailed:
+			status = payout.StatusFailed
+		}
+		_, err := exec.Exec("UPDATE Payout SET Status=?, BlockNum=?, UpdatedAt=? WHERE ID=? AND TrxID=?",
+			status, tx.BlockNum, time.Now(), tx.Ref, tx.TrxID)
+		return err
+	}
+	return nil
+}
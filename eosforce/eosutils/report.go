@@ -0,0 +1,87 @@
+package main
+
+import (
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+func runReport(args []string) error {
+	fs, cf := newFlagSet("report")
+	bp := fs.String("bp", "", "BP名字，不能为空.")
+	beginStr := fs.String("begin_time", "2018-06-01 00:00:00", "只统计在begin_time之后的投票。")
+	endStr := fs.String("end_time", "2200-01-01 00:00:00", "只统计在不晚于end_time的投票")
+	atBlock := fs.Uint64("at_block", 0, "按db中的历史重建截止到这个block时投票人对BP的有效投票，忽略begin_time/end_time.")
+	atStr := fs.String("at_time", "", "按db中的历史重建截止到这个时间时的有效投票，如 2019-06-01 00:00:00，可以和at_block同时使用.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if *bp == "" {
+		fs.Usage()
+		return fail(exitUsage, "missing bp param")
+	}
+	tmBegin, err := time.Parse(voters.TimeLayout, *beginStr)
+	if nil != err {
+		fs.Usage()
+		return fail(exitUsage, "begin_time '%s' invalid, should be like '%s'", *beginStr, voters.TimeLayout)
+	}
+	tmEnd, err := time.Parse(voters.TimeLayout, *endStr)
+	if nil != err {
+		fs.Usage()
+		return fail(exitUsage, "end_time '%s' invalid, should be like '%s'", *endStr, voters.TimeLayout)
+	}
+
+	dbmap, err := e.openDB(voters.AddTables)
+	if nil != err {
+		return err
+	}
+	if *atBlock > 0 || *atStr != "" {
+		asOf := voters.AsOf{BlockNum: *atBlock}
+		if *atStr != "" {
+			if asOf.Time, err = time.Parse(voters.TimeLayout, *atStr); nil != err {
+				fs.Usage()
+				return fail(exitUsage, "at_time '%s' invalid, should be like '%s'", *atStr, voters.TimeLayout)
+			}
+		}
+		return reportAsOf(dbmap, *bp, asOf)
+	}
+	var votes []*voters.VoteInfo
+	if _, err = dbmap.Select(&votes, "SELECT * FROM VoteInfo WHERE BPName=? AND BlockTime>=? AND BlockTime<=?",
+		*bp, tmBegin, tmEnd); nil != err {
+		return fail(exitDB, "select VoteInfo failed : %v", err)
+	}
+
+	voters.PrintReport(voters.Latest(votes))
+	return nil
+}
+
+// reportAsOf : 打印 asOf 时刻 bp 的投票人和总额，db 中的历史没有覆盖到 asOf 时提示结果可能不完整
+func reportAsOf(dbmap *gorp.DbMap, bp string, asOf voters.AsOf) error {
+	first, last, err := voters.Coverage(dbmap, bp)
+	if nil != err {
+		return fail(exitDB, "read coverage of %s failed : %v", bp, err)
+	}
+	if first == nil {
+		return fail(exitDB, "no votes of %s in db, run voters first", bp)
+	}
+	log.Infof("votes of %s in db : block %d (%s) to block %d (%s)", bp, first.BlockNum, first.BlockTime.Format(voters.TimeLayout),
+		last.BlockNum, last.BlockTime.Format(voters.TimeLayout))
+	if asOf.BlockNum > last.BlockNum || asOf.Time.After(last.BlockTime) {
+		log.Warnf("history of %s in db ends before the requested point, votes after block %d are unknown", bp, last.BlockNum)
+	}
+
+	votes, err := voters.Standing(dbmap, bp, asOf)
+	if nil != err {
+		return fail(exitDB, "select standing votes failed : %v", err)
+	}
+	voters.PrintReport(votes)
+	var total uint64
+	for _, v := range votes {
+		total += v.Quantity
+	}
+	log.Infof("%d voters, total %d %s", len(votes), total, first.Symbol)
+	return nil
+}
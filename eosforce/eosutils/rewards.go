@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/config"
+	"github.com/gpmn/eosutils/eosforce/stake"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+// runRewards : 按 StakeEvent 中的 claim 汇总 BP 发给投票人的分红，并和按票龄应得的份额比较
+func runRewards(args []string) error {
+	fs, cf := newFlagSet("rewards")
+	bp := fs.String("bp", "", "BP名字，不能为空.")
+	voter := fs.String("voter", "", "只显示这个投票人，份额仍按全部投票人计算.")
+	beginStr := fs.String("begin_time", "2018-06-01 00:00:00", "只统计在begin_time之后的分红。")
+	endStr := fs.String("end_time", "", "只统计不晚于end_time的分红，为空表示当前时间.")
+	interval := fs.String("interval", stake.IntervalMonth, "按 day、week 或 month 分段汇总.")
+	perVoter := fs.Bool("per_voter", false, "分段汇总时按投票人分别列出.")
+	tolerance := fs.Float64("tolerance", 0.5, "实际份额偏离应得份额超过这个比例时标记为异常.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if *bp == "" {
+		fs.Usage()
+		return fail(exitUsage, "missing bp param")
+	}
+	if e.chain.Dialect == config.DialectEOSIO {
+		return fail(exitUsage, "claim rewards only work with %s dialect", config.DialectEOSForce)
+	}
+	if _, err = stake.PeriodStart(time.Now(), *interval); nil != err {
+		fs.Usage()
+		return fail(exitUsage, "%v", err)
+	}
+	tmBegin, err := time.Parse(voters.TimeLayout, *beginStr)
+	if nil != err {
+		fs.Usage()
+		return fail(exitUsage, "begin_time '%s' invalid, should be like '%s'", *beginStr, voters.TimeLayout)
+	}
+	tmEnd := time.Now().UTC()
+	if *endStr != "" {
+		if tmEnd, err = time.Parse(voters.TimeLayout, *endStr); nil != err {
+			fs.Usage()
+			return fail(exitUsage, "end_time '%s' invalid, should be like '%s'", *endStr, voters.TimeLayout)
+		}
+	}
+
+	dbmap, err := e.openDB(stake.AddTables)
+	if nil != err {
+		return err
+	}
+	events, err := stake.Events(dbmap, "", *bp)
+	if nil != err {
+		return fail(exitDB, "select StakeEvent failed : %v", err)
+	}
+	if len(events) == 0 {
+		return fail(exitDB, "no stake events of %s in db, run voters or follow first", *bp)
+	}
+
+	// 没有记录到 inline transfer 的 claim 领到多少不知道，不计入汇总
+	var claims []*stake.Event
+	var unknown int
+	for _, ev := range events {
+		if ev.Action != stake.ActionClaim || ev.BlockTime.Before(tmBegin) || ev.BlockTime.After(tmEnd) {
+			continue
+		}
+		if *voter != "" && ev.Voter != *voter {
+			continue
+		}
+		if ev.Reward == 0 {
+			unknown++
+			continue
+		}
+		claims = append(claims, ev)
+	}
+	if unknown > 0 {
+		log.Warnf("%d of %d claims have no reward recorded and are left out of the totals, "+
+			"record them with follow -traces or ship", unknown, unknown+len(claims))
+	}
+
+	periods, err := stake.Periods(claims, *interval, *perVoter || *voter != "")
+	if nil != err {
+		return fail(exitUsage, "%v", err)
+	}
+	log.Infof("%-10s %-12s %-12s %-6s %s", "PERIOD", "BP", "VOTER", "CLAIMS", "REWARD")
+	for _, p := range periods {
+		log.Infof("%-10s %-12s %-12s %-6d %s", p.Start.Format("2006-01-02"), p.BPName, p.Voter, p.Claims, e.asset(p.Reward))
+	}
+
+	anomalies := 0
+	log.Infof("%-12s %-6s %-16s %-10s %-10s", "VOTER", "CLAIMS", "REWARD", "ACTUAL", "EXPECTED")
+	shares, left := stake.Shares(events, tmBegin, tmEnd)
+	for _, s := range shares {
+		if *voter != "" && s.Voter != *voter {
+			continue
+		}
+		mark := ""
+		if s.Anomalous(*tolerance) {
+			mark = "ANOMALY"
+			anomalies++
+		}
+		log.Infof("%-12s %-6d %-16s %-9.4f%% %-9.4f%% %s", s.Voter, s.Claims, e.asset(s.Reward), s.Actual*100, s.Expected*100, mark)
+	}
+	log.Infof("%d anomalies with tolerance %.0f%%", anomalies, *tolerance*100)
+	if len(left) > 0 {
+		log.Warnf("%d voters with claims of unknown reward are left out of the shares : %s", len(left), strings.Join(left, ","))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/accounts"
+	"github.com/gpmn/eosutils/eosforce/api"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+// runServe : 通过 HTTP 提供 db 中数据的 json 查询，接口见 api 包
+func runServe(args []string) error {
+	fs, cf := newFlagSet("serve")
+	listen := fs.String("listen", "127.0.0.1:8000", "监听地址.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	dbmap, err := e.openDB(voters.AddTables, accounts.AddTables)
+	if nil != err {
+		return err
+	}
+	srv := &http.Server{
+		Addr:         *listen,
+		Handler:      &api.Server{DB: dbmap},
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	stop := newStopper()
+	stop.watchSignals()
+	go func() {
+		<-stop.ch
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	log.Infof("serve - listen on %s", *listen)
+	if err = srv.ListenAndServe(); err != http.ErrServerClosed {
+		return fail(exitFailed, "listen %s failed : %v", *listen, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gpmn/eosutils/eosforce/config"
+	"github.com/gpmn/eosutils/eosforce/follow"
+	"github.com/gpmn/eosutils/eosforce/ship"
+	"github.com/gpmn/eosutils/eosforce/stake"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+// runShip : 通过 state history 插件跟随链，Handler 和断点与 follow 命令相同
+func runShip(args []string) error {
+	fs, cf := newFlagSet("ship")
+	url := fs.String("url", "ws://127.0.0.1:8080", "state history 插件的 websocket 地址.")
+	start := fs.Uint64("start", 1, "没有断点时从哪个block开始.")
+	end := fs.Uint64("end", 0, "处理到哪个block为止(不含)，0表示一直跟随.")
+	name := fs.String("name", "ship", "断点名，同一个db里的多个follow用不同的名字.")
+	bp := fs.String("bp", "", "把投给这个BP的投票写入VoteInfo，为空则不解析投票.")
+	actions := fs.String("actions", "", "原样记录到ActionLog的action，逗号分隔，如 eosio.token:transfer,eosio:* .")
+	deltas := fs.String("deltas", "", "记录到DeltaLog的合约表变化，逗号分隔，如 eosio:voters ，为空则不请求表变化.")
+	record := fs.String("record", "", "把收到的帧录到这个文件，供 shipreplay 回放.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	actionLog := follow.NewActionLog(*actions)
+	deltaLog := follow.NewDeltaLog(*deltas)
+	if *bp == "" && actionLog.Empty() && deltaLog.Empty() {
+		fs.Usage()
+		return fail(exitUsage, "nothing to follow, set bp, actions or deltas")
+	}
+	dbmap, err := e.openDB(follow.AddTables, follow.AddActionTables, follow.AddDeltaTables, voters.AddTables, stake.AddTables)
+	if nil != err {
+		return err
+	}
+
+	p := &follow.Pipeline{Name: *name, DB: dbmap}
+	if *bp != "" {
+		last, err := p.LastBlock()
+		if nil != err {
+			return fail(exitDB, "read checkpoint %s failed : %v", *name, err)
+		}
+		if err = voters.CheckKeys(dbmap, false); nil != err {
+			return fail(exitDB, "ship %s : %v", *name, err)
+		}
+		fromGenesis := last == 0 && *start <= 1
+		p.Handlers = append(p.Handlers, voters.NewHandler(e.nodeURL, *bp, e.chain.Dialect, e.chain.CoreSymbol, e.chain.Precision, fromGenesis))
+		if e.chain.Dialect != config.DialectEOSIO {
+			p.Handlers = append(p.Handlers, &stake.Handler{BP: *bp, Token: e.chain.Token, Symbol: e.chain.CoreSymbol, Precision: e.chain.Precision})
+		}
+	}
+	if !actionLog.Empty() {
+		p.Handlers = append(p.Handlers, actionLog)
+	}
+	if !deltaLog.Empty() {
+		p.Handlers = append(p.Handlers, deltaLog)
+	}
+
+	client := &ship.Client{URL: *url, Start: *start, End: *end, Deltas: !deltaLog.Empty()}
+	if *record != "" {
+		file, err := os.OpenFile(*record, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if nil != err {
+			return fail(exitUsage, "open %s failed : %v", *record, err)
+		}
+		defer file.Close()
+		client.Record = file
+	}
+
+	stop := newStopper()
+	stop.watchSignals()
+	if err = client.Run(p, stop.ch); nil != err {
+		return fail(exitDB, "ship %s failed : %v", *name, err)
+	}
+	if stop.code != 0 {
+		return fail(stop.code, "ship stopped")
+	}
+	return nil
+}
+
+// runShipReplay : 回放 ship -record 录下的帧，充当 state history 插件
+func runShipReplay(args []string) error {
+	fs, cf := newFlagSet("shipreplay")
+	listen := fs.String("listen", "127.0.0.1:8080", "监听地址.")
+	frames := fs.String("frames", "", "ship -record 录下的文件.")
+	if _, err := parse(fs, cf, args); nil != err {
+		return err
+	}
+	if *frames == "" {
+		fs.Usage()
+		return fail(exitUsage, "frames param missed")
+	}
+	log.Infof("shipreplay - replay %s on ws://%s", *frames, *listen)
+	if err := http.ListenAndServe(*listen, ship.ReplayHandler(*frames)); nil != err {
+		return fail(exitFailed, "listen %s failed : %v", *listen, err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/budget"
+	"github.com/gpmn/eosutils/eosforce/confirm"
+	"github.com/gpmn/eosutils/eosforce/optout"
+)
+
+// sendHistory : 快照模式的发送进度，每发送一条都原子地写回文件
+type sendHistory struct {
+	LastOk   string // 最后一个发送成功的账号
+	Line     int    // 已处理完的快照行数，续传时直接跳过这些行
+	Account  string // 第Line行的账号，续传时用来校验快照没有变化
+	InFlight string // 正在发送、结果未知的账号，续传时跳过，宁可少发不重发
+}
+
+func (his *sendHistory) load(path string) error {
+	buf, err := ioutil.ReadFile(path)
+	if nil != err {
+		log.Errorf("load history file %s failed : %v", path, err)
+		return err
+	}
+	err = json.Unmarshal(buf, his)
+	if nil != err {
+		log.Errorf("json.unmarshal failed : %v", err)
+		return err
+	}
+	return nil
+}
+
+func (his *sendHistory) save(path string) error {
+	buf, err := json.Marshal(*his)
+	if nil != err {
+		log.Errorf("json.Marshal failed : %s", err.Error())
+		return err
+	}
+	// 先写临时文件再rename，中途崩溃也不会留下写了一半的进度
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if nil != err {
+		log.Errorf("open %s failed : %v", tmp, err)
+		return err
+	}
+	if _, err = file.Write(buf); nil == err {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); nil == err {
+		err = cerr
+	}
+	if nil != err {
+		log.Errorf("write %s failed : %v", tmp, err)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// broadcastSnapshot : 按创世快照csv的顺序，向持仓不少于 valve 的账号发送广告，进度保存在 hisPath
+func broadcastSnapshot(dbmap *gorp.DbMap, w *wallet, task *advTask, snapPath, hisPath string, valve float64, bgt *budget.Budget, stop *stopper) error {
+	history := sendHistory{LastOk: ""}
+	if err := history.load(hisPath); nil != err {
+		log.Warnf("load history failed : %v, used default.", err)
+	}
+
+	file, err := os.Open(snapPath)
+	if nil != err {
+		return fail(exitUsage, "open file %s failed : %v", snapPath, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	legacySkip := history.Line == 0 && history.LastOk != ""
+	skipInFlight := history.InFlight
+	if skipInFlight != "" {
+		log.Warnf("message to %s may have been sent before last exit, skip it", skipInFlight)
+		history.InFlight = ""
+	}
+	cnt := 0
+	lineNo := 0
+	// 返回前保存进度
+	exit := func(code int, format string, args ...interface{}) error {
+		if err := history.save(hisPath); nil != err {
+			log.Errorf("save history failed : %v.", err)
+		}
+		log.Infof("sent %d messages, processed %d lines, last ok %s", cnt, history.Line, history.LastOk)
+		if code == exitOK {
+			return nil
+		}
+		return fail(code, format, args...)
+	}
+	for {
+		select {
+		case <-stop.ch:
+			return exit(stop.code, "stop sending")
+		default:
+		}
+
+		line, err := reader.Read()
+		if err == io.EOF {
+			return exit(exitOK, "")
+		}
+		if err != nil {
+			return exit(exitUsage, "reader.Read failed : %v", err)
+		}
+		lineNo++
+		account := line[1]
+
+		if lineNo < history.Line {
+			continue
+		}
+		if lineNo == history.Line {
+			if account != history.Account {
+				return fail(exitUsage, "line %d is %s, history says %s, snapshot changed ?", lineNo, account, history.Account)
+			}
+			continue
+		}
+		if legacySkip {
+			// 旧格式的进度文件只有LastOk，跳到LastOk之后
+			if account == history.LastOk {
+				legacySkip = false
+			}
+			continue
+		}
+		log.Debugf("%v", line)
+
+		processed := func() {
+			history.Line, history.Account = lineNo, account
+			if lineNo%100 == 0 {
+				history.save(hisPath)
+			}
+		}
+
+		quantity, err := strconv.ParseFloat(line[3], 64)
+		if nil != err {
+			return exit(exitUsage, "strconv.ParseFloat(%s,64) failed : %v", line[3], err)
+		}
+		if quantity < valve {
+			processed()
+			continue
+		}
+
+		suppressed, err := optout.IsSuppressed(dbmap, account)
+		if nil != err {
+			return exit(exitDB, "optout.IsSuppressed(%s) failed : %v", account, err)
+		}
+		if suppressed || account == skipInFlight {
+			log.Debugf("%s opted out or in flight last time, skip", account)
+			skipInFlight = ""
+			processed()
+			continue
+		}
+
+		history.InFlight = account
+		if err = history.save(hisPath); nil != err {
+			return fail(exitFailed, "save history failed : %v", err)
+		}
+		var retry int
+		var result *transferResult
+		for retry = 0; retry < 10; retry++ {
+			if err = bgt.Spend(task.amount, stop.ch); nil != err {
+				history.InFlight = ""
+				if err == budget.ErrStopped {
+					return exit(stop.code, "stop sending")
+				}
+				return exit(exitSend, "budget.Spend : %v, stop sending", err)
+			}
+			if result, err = w.transfer(task.from, account, task.quantity, task.adv); nil == err || !retryableSend(err) {
+				break
+			}
+			select {
+			case <-time.After(budget.Backoff(retry)):
+			case <-stop.ch:
+				history.InFlight = ""
+				return exit(stop.code, "stop while retrying %s", account)
+			}
+		}
+		if nil != err {
+			// 结果未知的保留 InFlight，下次运行跳过，避免重复发送
+			if !errors.Is(err, errSendUnknown) {
+				history.InFlight = ""
+			}
+			return exit(exitSend, "send to %s failed, stop sending : %v", account, err)
+		}
+		if result.TransactionID != "" {
+			if err = confirm.Track(dbmap, "snap", account, result.TransactionID, result.Processed.BlockNum, result.Expiration); nil != err {
+				log.Errorf("confirm.Track(%s) failed : %v", result.TransactionID, err)
+			}
+		}
+		cnt++
+		history.LastOk, history.InFlight = account, ""
+		history.Line, history.Account = lineNo, account
+		if err = history.save(hisPath); nil != err {
+			return fail(exitFailed, "save history failed : %v", err)
+		}
+	}
+}
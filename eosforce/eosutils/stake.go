@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/gpmn/eosutils/eosforce/config"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/stake"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+// runStake : 按 StakeEvent 重放并打印一个投票人的抵押、解冻和领取分红的全过程
+func runStake(args []string) error {
+	fs, cf := newFlagSet("stake")
+	voter := fs.String("voter", "", "投票人，不能为空.")
+	bp := fs.String("bp", "", "只看对这个BP的抵押，为空看全部BP.")
+	atBlock := fs.Uint64("at_block", 0, "按哪个block计算解冻中和可取回的额度，0表示节点当前的head block.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if *voter == "" {
+		fs.Usage()
+		return fail(exitUsage, "missing voter param")
+	}
+	if e.chain.Dialect == config.DialectEOSIO {
+		return fail(exitUsage, "stake ledger only works with %s dialect", config.DialectEOSForce)
+	}
+	dbmap, err := e.openDB(stake.AddTables)
+	if nil != err {
+		return err
+	}
+	events, err := stake.Events(dbmap, *voter, *bp)
+	if nil != err {
+		return fail(exitDB, "select StakeEvent failed : %v", err)
+	}
+	if len(events) == 0 {
+		return fail(exitDB, "no stake events of %s in db, run voters or follow first", *voter)
+	}
+
+	states := stake.Replay(events)
+	at := *atBlock
+	if at == 0 {
+		info, err := eosapi.GetInfo(e.nodeURL)
+		if nil != err {
+			at = events[len(events)-1].BlockNum
+			log.Warnf("get_info failed, settle at last event block %d : %v", at, err)
+		} else {
+			at = info.HeadBlockNum
+		}
+	}
+
+	log.Infof("%-19s %-10s %-12s %-8s %-16s %-16s %-16s %s", "TIME", "BLOCK", "BP", "ACTION", "STAKED", "UNFREEZING", "CLAIMABLE", "UNLOCK")
+	latest := make(map[string]*stake.State) // bp -> 最后的状态
+	for _, st := range states {
+		ev := st.Event
+		unlock := ""
+		if st.Unstaking > 0 {
+			unlock = st.UnlockTime().Format(voters.TimeLayout)
+		}
+		log.Infof("%-19s %-10d %-12s %-8s %-16s %-16s %-16s %s", ev.BlockTime.Format(voters.TimeLayout), ev.BlockNum, ev.BPName, ev.Action,
+			e.asset(st.Staked), e.asset(st.Unfreezing(ev.BlockNum)), e.asset(st.Claimable(ev.BlockNum)), unlock)
+		latest[ev.BPName] = st
+	}
+
+	var bps []string
+	for name := range latest {
+		bps = append(bps, name)
+	}
+	sort.Strings(bps)
+	var staked, unfreezing, claimable uint64
+	log.Infof("at block %d :", at)
+	for _, name := range bps {
+		st := latest[name]
+		claimed := "never"
+		if !st.LastClaim.IsZero() {
+			claimed = st.LastClaim.Format(voters.TimeLayout)
+		}
+		log.Infof("  %-12s staked %s, unfreezing %s, claimable %s, last claim %s", name,
+			e.asset(st.Staked), e.asset(st.Unfreezing(at)), e.asset(st.Claimable(at)), claimed)
+		staked += st.Staked
+		unfreezing += st.Unfreezing(at)
+		claimable += st.Claimable(at)
+	}
+	log.Infof("  %-12s staked %s, unfreezing %s, claimable %s", "TOTAL", e.asset(staked), e.asset(unfreezing), e.asset(claimable))
+	return nil
+}
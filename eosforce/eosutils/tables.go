@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+)
+
+// runTables : 逐页读取合约表，每行一个JSON输出到标准输出
+func runTables(args []string) error {
+	fs, cf := newFlagSet("tables")
+	code := fs.String("code", "eosio", "合约账号.")
+	scope := fs.String("scope", "", "表的scope，为空则同code.")
+	table := fs.String("table", "", "表名，不能为空.")
+	lower := fs.String("lower_bound", "", "主键下限.")
+	limit := fs.Int("limit", 500, "每页行数.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if *table == "" {
+		fs.Usage()
+		return fail(exitUsage, "missing table param")
+	}
+	if *scope == "" {
+		*scope = *code
+	}
+
+	query := &eosapi.TableQuery{Code: *code, Scope: *scope, Table: *table, LowerBound: *lower, Limit: *limit}
+	seen := make(map[string]bool) // lower_bound 包含边界，翻页时第一行会重复
+	for {
+		resp, err := eosapi.GetTableRows(e.nodeURL, query)
+		if nil != err {
+			return fail(exitRPC, "get_table_rows %s/%s/%s failed : %v", *code, *scope, *table, err)
+		}
+		for _, row := range resp.Rows {
+			if seen[string(row)] {
+				continue
+			}
+			seen[string(row)] = true
+			fmt.Fprintf(os.Stdout, "%s\n", row)
+		}
+		if !resp.More || len(resp.Rows) == 0 {
+			return nil
+		}
+		next, err := eosapi.RowKey(resp.Rows[len(resp.Rows)-1])
+		if nil != err {
+			return fail(exitRPC, "can not page table %s : %v", *table, err)
+		}
+		if next == query.LowerBound {
+			return fail(exitRPC, "table %s does not advance at %s", *table, next)
+		}
+		query.LowerBound = next
+		seen = map[string]bool{string(resp.Rows[len(resp.Rows)-1]): true}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/budget"
+	"github.com/gpmn/eosutils/eosforce/config"
+	"github.com/gpmn/eosutils/eosforce/confirm"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/metrics"
+)
+
+type transferResult struct {
+	TransactionID string `json:"transaction_id"`
+	Processed     struct {
+		BlockNum uint64 `json:"block_num"`
+	} `json:"processed"`
+	Expiration time.Time `json:"-"` // 签名交易里的过期时间
+}
+
+// wallet : 通过 cleos 发送转账
+type wallet struct {
+	cleos     string
+	walletURL string
+	nodeURL   string
+	chain     *config.Chain
+}
+
+func newWallet(e *env) *wallet {
+	return &wallet{cleos: e.cfg.Wallet.Cleos, walletURL: e.cfg.Wallet.URL, nodeURL: e.nodeURL, chain: e.chain}
+}
+
+// asset : 把核心币最小单位的数量格式化成 cleos 可用的资产字符串
+func (w *wallet) asset(amount uint64) string {
+	return eosapi.FormatAsset(amount, w.chain.Precision, w.chain.CoreSymbol)
+}
+
+// sendError : cleos 转账失败
+type sendError struct {
+	err   error
+	fatal bool // 链上拒绝（断言失败、权限不符、钱包锁定等），重试也不会成功
+}
+
+func (e *sendError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sendError) Unwrap() error {
+	return e.err
+}
+
+// cleos 报错形如 "Error 3050003: eosio_assert_message assertion failure"，这些类别的错误重试无用
+var (
+	cleosErrRe      = regexp.MustCompile(`Error (\d{4})\d{3}:`)
+	fatalCleosClass = map[string]bool{
+		"3010": true, // 名字、资产等格式错误
+		"3050": true, // action 校验和断言失败，如余额不足
+		"3090": true, // 权限不符
+		"3120": true, // 钱包错误，如钱包锁定、找不到私钥
+	}
+)
+
+// errSendUnknown : cleos 推送后的输出解析不了，交易可能已经广播，不能直接重发
+var errSendUnknown = errors.New("unrecognized cleos output, transaction may have been broadcast")
+
+// retryableSend : 转账失败后能否重试，连接失败、超时、资源不足等可以重试
+func retryableSend(err error) bool {
+	var sendErr *sendError
+	return !errors.As(err, &sendErr) || !sendErr.fatal
+}
+
+// runCleos : 执行一次 cleos，失败时按 stderr 里的错误类别判断能否重试
+func (w *wallet) runCleos(args ...string) ([]byte, error) {
+	cmd := exec.Command(w.cleos, append([]string{"--wallet-url", w.walletURL, "-u", w.nodeURL}, args...)...)
+	stdout, err := cmd.Output()
+	if err != nil {
+		fatal := false
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			log.Errorf("cleos %s failed, stderr : %s", args[0], exitErr.Stderr)
+			if m := cleosErrRe.FindSubmatch(exitErr.Stderr); m != nil {
+				fatal = fatalCleosClass[string(m[1])]
+			}
+		}
+		return nil, &sendError{err: err, fatal: fatal}
+	}
+	return stdout, nil
+}
+
+// transfer : 先用 cleos 签名但不广播，取得交易的过期时间，再把签好的交易推给节点。
+// 推送后的输出解析不了或没有 transaction_id 时返回 errSendUnknown，不可重试
+func (w *wallet) transfer(from, to, quantity, memo string) (*transferResult, error) {
+	eosapi.Wait(w.nodeURL)
+	signed, err := w.runCleos("transfer", from, to, quantity, memo, "--dont-broadcast", "-j")
+	if nil != err {
+		metrics.Transfer(err)
+		log.Errorf("transfer %s -> %s %s sign failed, err : %v", from, to, quantity, err)
+		return nil, err
+	}
+	var trx struct {
+		Expiration string `json:"expiration"`
+	}
+	var result transferResult
+	if err = json.Unmarshal(signed, &trx); nil == err {
+		result.Expiration, err = time.Parse(eosapi.TimeLayout, trx.Expiration)
+	}
+	if nil != err {
+		// 还没有广播，重试也一样解析不了
+		metrics.Transfer(err)
+		log.Errorf("transfer - unrecognized signed transaction : %v\n%s", err, signed)
+		return nil, &sendError{err: err, fatal: true}
+	}
+
+	stdout, err := w.runCleos("push", "transaction", "--skip-sign", "-j", string(signed))
+	metrics.Transfer(err)
+	if nil != err {
+		log.Errorf("transfer %s -> %s %s failed, err : %v", from, to, quantity, err)
+		return nil, err
+	}
+	if err = json.Unmarshal(stdout, &result); nil != err || result.TransactionID == "" {
+		log.Errorf("transfer - unrecognized cleos output : %v\n%s", err, stdout)
+		return nil, &sendError{err: fmt.Errorf("transfer %s -> %s %s : %w", from, to, quantity, errSendUnknown), fatal: true}
+	}
+	return &result, nil
+}
+
+// stopper : 任一goroutine遇到致命错误、预算用完或收到信号时通知全部停止，保留第一个退出码
+type stopper struct {
+	once sync.Once
+	ch   chan struct{}
+	code int
+}
+
+func newStopper() *stopper {
+	return &stopper{ch: make(chan struct{})}
+}
+
+func (s *stopper) stop(code int) {
+	s.once.Do(func() {
+		s.code = code
+		close(s.ch)
+	})
+}
+
+// watchSignals : 收到 SIGINT/SIGTERM 时停止，正在发送的交易会处理完
+func (s *stopper) watchSignals() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		log.Warnf("got signal %v, finishing in-flight transfers", sig)
+		s.stop(exitInterrupted)
+	}()
+}
+
+// sendFlags : 发送类子命令共用的预算和确认参数
+type sendFlags struct {
+	from            *string
+	maxAmount       *float64
+	maxFee          *float64
+	fee             *float64
+	perMinute       *int
+	waitConfirm     *bool
+	confirmInterval *time.Duration
+}
+
+func addSendFlags(fs *flag.FlagSet, maxAmount float64, perMinute int) *sendFlags {
+	return &sendFlags{
+		from:            fs.String("from", "", "由谁发送，不能为空."),
+		maxAmount:       fs.Float64("max_amount", maxAmount, "本次运行最多转出多少核心币（不含手续费）."),
+		maxFee:          fs.Float64("max_fee", 100, "本次运行最多花费多少核心币手续费，0表示不限制."),
+		fee:             fs.Float64("fee", 0.01, "每笔转账的手续费，标准eosio链填0."),
+		perMinute:       fs.Int("per_minute", perMinute, "每分钟最多发送多少笔，0表示不限制."),
+		waitConfirm:     fs.Bool("confirm", true, "发送完后等待交易不可逆，过期的交易会重新排队。"),
+		confirmInterval: fs.Duration("confirm_interval", 10*time.Second, "查询交易状态的间隔."),
+	}
+}
+
+func (sf *sendFlags) budget(precision int) *budget.Budget {
+	return budget.New(eosapi.ToUnits(*sf.maxAmount, precision), eosapi.ToUnits(*sf.maxFee, precision), eosapi.ToUnits(*sf.fee, precision), *sf.perMinute)
+}
+
+// waitConfirmed : 按参数等待交易确认
+func (sf *sendFlags) waitConfirmed(dbmap *gorp.DbMap, e *env, stop *stopper) error {
+	if !*sf.waitConfirm {
+		return nil
+	}
+	if err := confirm.Wait(dbmap, e.nodeURL, *sf.confirmInterval, onSettled, stop.ch); nil != err {
+		return fail(exitRPC, "confirm.Wait failed : %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/config"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/payout"
+	"github.com/gpmn/eosutils/eosforce/stake"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+func runVoters(args []string) error {
+	fs, cf := newFlagSet("voters")
+	mode := fs.String("mode", "forward", "forward: 从旧到新读取; reverse: 从新到旧回溯，不支持标准eosio链.")
+	beginNum := fs.Uint64("begin_num", 0, "只统计不早于这个block number的action，0表示不限制。reverse 模式读到它之前就停止。")
+	fromPos := fs.Uint64("from_pos", 0, "从哪个account_action_seq开始，不是block number，0表示forward从最早、reverse从最新开始。参见pos : https://documenter.getpostman.com/view/4394576/RWEnobze#4cc4d825-2bad-4677-a7f3-d8971e7cb89a")
+	beginStr := fs.String("begin_time", "2018-06-01 00:00:00", "只统计在begin_time之后的Block。")
+	endStr := fs.String("end_time", "2200-01-01 00:00:00", "只统计在不晚于end_time的Block，forward 模式读到它之后就停止。")
+	bp := fs.String("bp", "", "查询的BP名字，不能为空.")
+	nosave := fs.Bool("nosave", false, "不保存到db，只打印报表.")
+	ondup := fs.String("ondup", "query", "如果db已有重复SeqNum记录，是继续、还是退出、还是询问,即 goon/term/query 三个选项。")
+	payoutTotal := fs.Float64("payout_total", 0, "按投票额度把这么多核心币分给投票人，写入db的Payout表，供payout命令发送。0表示不生成。")
+	payoutMemo := fs.String("payout_memo", "", "分红转账的备注.")
+	payoutRound := fs.String("payout_round", "", "分红轮次，同一轮次只能生成一次付款，为空时用 payout_memo@end_time.")
+	prefetch := fs.Int("prefetch", 4, "提前并发请求几页get_actions，1表示逐页顺序请求.")
+	perSecond := fs.Float64("per_second", 0, "每秒最多请求几页get_actions，0表示不限，公共节点限流时调小.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if *bp == "" {
+		fs.Usage()
+		return fail(exitUsage, "missing bp param")
+	}
+	if *payoutTotal > 0 && *nosave {
+		fs.Usage()
+		return fail(exitUsage, "payout_total can not work with nosave")
+	}
+
+	if *mode != "forward" && *mode != "reverse" {
+		fs.Usage()
+		return fail(exitUsage, "mode '%s' invalid, should be forward or reverse", *mode)
+	}
+	if *mode == "reverse" && e.chain.Dialect == config.DialectEOSIO {
+		return fail(exitUsage, "reverse mode does not work with %s dialect", e.chain.Dialect)
+	}
+
+	tmBegin, err := time.Parse(voters.TimeLayout, *beginStr)
+	if nil != err {
+		fs.Usage()
+		return fail(exitUsage, "begin_time '%s' invalid, should be like '%s'", *beginStr, voters.TimeLayout)
+	}
+	tmEnd, err := time.Parse(voters.TimeLayout, *endStr)
+	if nil != err {
+		fs.Usage()
+		return fail(exitUsage, "end_time '%s' invalid, should be like '%s'", *endStr, voters.TimeLayout)
+	}
+
+	if err = e.verifyChain(); nil != err {
+		return err
+	}
+	var dbmap *gorp.DbMap
+	if !*nosave {
+		if dbmap, err = e.openDB(voters.AddTables, payout.AddTables, stake.AddTables); nil != err {
+			return err
+		}
+		if err = voters.CheckKeys(dbmap, false); nil != err {
+			return fail(exitDB, "crawl votes of %s : %v", *bp, err)
+		}
+	}
+
+	crawler := &voters.Crawler{
+		NodeURL:   e.nodeURL,
+		BP:        *bp,
+		Dialect:   e.chain.Dialect,
+		Token:     e.chain.Token,
+		Symbol:    e.chain.CoreSymbol,
+		Precision: e.chain.Precision,
+		BeginNum:  *beginNum,
+		FromPos:   *fromPos,
+		Reverse:   *mode == "reverse",
+		Begin:     tmBegin,
+		End:       tmEnd,
+		OnDup:     *ondup,
+		DB:        dbmap,
+		Prefetch:  *prefetch,
+		PerSecond: *perSecond,
+	}
+	votes, err := crawler.Run()
+	if nil != err {
+		return fail(exitRPC, "crawl votes of %s failed : %v", *bp, err)
+	}
+
+	voteList := voters.Latest(votes)
+	voters.PrintReport(voteList)
+
+	if *payoutTotal > 0 {
+		round := *payoutRound
+		if round == "" {
+			round = *payoutMemo + "@" + *endStr
+		}
+		return planPayout(dbmap, voteList, eosapi.ToUnits(*payoutTotal, e.chain.Precision), round, *payoutMemo)
+	}
+	return nil
+}
+
+// planPayout : 按投票额度生成分红付款
+func planPayout(dbmap *gorp.DbMap, voteList voters.VoteArray, total uint64, round, memo string) error {
+	var shares []payout.Share
+	for _, v := range voteList {
+		shares = append(shares, payout.Share{Account: v.Voter, Weight: v.Quantity})
+	}
+	payouts := payout.Plan(shares, total, round, memo)
+	if err := payout.Save(dbmap, payouts); nil != err {
+		return fail(exitDB, "payout.Save failed : %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/follow"
+	"github.com/gpmn/eosutils/eosforce/voters"
+	"github.com/gpmn/eosutils/eosforce/webhook"
+)
+
+// runWatch : 跟随新的投票，投票人加入、离开或额度变化时推送 webhook
+func runWatch(args []string) error {
+	fs, cf := newFlagSet("watch")
+	bps := fs.String("bps", "", "关注的BP，逗号分隔，不能为空.")
+	url := fs.String("url", "", "webhook地址，为空则用配置的 webhook.url .")
+	secret := fs.String("secret", "", "HMAC签名密钥，为空则用配置的 webhook.secret .")
+	threshold := fs.Uint64("threshold", 1000, "投票额度变化不小于这么多核心币才推送 stake_change.")
+	start := fs.Uint64("start", 0, "没有断点时从哪个block开始，0表示从当前不可逆块开始.")
+	name := fs.String("name", "watch", "断点名.")
+	traces := fs.Bool("traces", false, "用 trace_api 插件取block，包含inline action.")
+	interval := fs.Duration("interval", 3*time.Second, "追上不可逆块后的查询间隔，也是投递webhook的间隔.")
+	maxAttempts := fs.Int("max_attempts", 10, "每个事件最多投递几次.")
+	e, err := parse(fs, cf, args)
+	if nil != err {
+		return err
+	}
+
+	if *url == "" {
+		*url = e.cfg.Webhook.URL
+	}
+	if *secret == "" {
+		*secret = e.cfg.Webhook.Secret
+	}
+	var bpList []string
+	for _, bp := range strings.Split(*bps, ",") {
+		if bp = strings.TrimSpace(bp); bp != "" {
+			bpList = append(bpList, bp)
+		}
+	}
+	if len(bpList) == 0 || *url == "" {
+		fs.Usage()
+		return fail(exitUsage, "bps and url are required")
+	}
+	if *secret == "" {
+		log.Warnf("watch - no secret, webhooks will not be signed")
+	}
+	if err = e.verifyChain(); nil != err {
+		return err
+	}
+	dbmap, err := e.openDB(follow.AddTables, voters.AddTables, webhook.AddTables)
+	if nil != err {
+		return err
+	}
+
+	f := &follow.Follower{
+		Pipeline: follow.Pipeline{Name: *name, DB: dbmap},
+		NodeURL:  e.nodeURL,
+		Start:    *start,
+		Traces:   *traces,
+		Interval: *interval,
+	}
+	last, err := f.LastBlock()
+	if nil != err {
+		return fail(exitDB, "read checkpoint %s failed : %v", *name, err)
+	}
+	if last == 0 && f.Start == 0 {
+		info, err := eosapi.GetInfo(e.nodeURL)
+		if nil != err {
+			return fail(exitRPC, "get_info failed : %v", err)
+		}
+		f.Start = info.LastIrreversibleBlockNum
+	}
+
+	onVote := func(exec gorp.SqlExecutor, prev, cur *voters.VoteInfo) error {
+		kind := voters.Diff(prev, cur, *threshold)
+		if kind == voters.ChangeNone {
+			return nil
+		}
+		log.Infof("watch - %s %s -> %s, %d", kind, cur.Voter, cur.BPName, cur.Quantity)
+		return webhook.Enqueue(exec, *url, kind, prev, cur)
+	}
+	for _, bp := range bpList {
+		h := voters.NewHandler(e.nodeURL, bp, e.chain.Dialect, e.chain.CoreSymbol, e.chain.Precision, false)
+		h.OnVote = onVote
+		f.Handlers = append(f.Handlers, h)
+	}
+
+	stop := newStopper()
+	stop.watchSignals()
+	sender := &webhook.Sender{Secret: *secret, MaxAttempts: *maxAttempts}
+	senderDone := make(chan error, 1)
+	go func() {
+		senderDone <- sender.Run(dbmap, *interval, stop.ch)
+	}()
+
+	err = f.Run(stop.ch)
+	if nil != err {
+		stop.stop(exitDB)
+	}
+	if serr := <-senderDone; nil != serr && nil == err {
+		return fail(exitDB, "webhook delivery failed : %v", serr)
+	}
+	if nil != err {
+		return fail(exitDB, "watch %s failed : %v", *name, err)
+	}
+	if stop.code != 0 {
+		return fail(stop.code, "watch stopped")
+	}
+	return nil
+}
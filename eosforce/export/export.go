@@ -0,0 +1,235 @@
+// Package export 把 db 中的表逐行导出成 CSV、JSON Lines 或 Parquet。
+//
+// 列的类型取自库中声明的类型（integer/bigint、real/double、boolean、datetime/timestamp，其余按字符串），
+// CSV 和 JSON Lines 中时间为 UTC 的 RFC3339，Parquet 中各列都是 optional，时间为毫秒精度的 timestamp。
+package export
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/logging"
+)
+
+var log = logging.New("export")
+
+// 导出格式
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+// Kind : 列的类型
+type Kind int
+
+// 列的类型
+const (
+	KindString Kind = iota
+	KindInt
+	KindFloat
+	KindBool
+	KindTime
+)
+
+// Column : 导出的一列
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// KindOf : 按库中声明的类型名确定列的类型
+func KindOf(dbType string) Kind {
+	t := strings.ToLower(dbType)
+	switch {
+	case strings.Contains(t, "int") || strings.Contains(t, "serial"):
+		return KindInt
+	case strings.Contains(t, "bool"):
+		return KindBool
+	case strings.Contains(t, "date") || strings.Contains(t, "time"):
+		return KindTime
+	case strings.Contains(t, "real") || strings.Contains(t, "double") || strings.Contains(t, "float") ||
+		strings.Contains(t, "numeric") || strings.Contains(t, "decimal"):
+		return KindFloat
+	}
+	return KindString
+}
+
+// Writer : 一种格式的输出
+type Writer interface {
+	// Write : 写一行，vals 已按列类型转换，NULL 为 nil
+	Write(vals []interface{}) error
+	// Close : 写完剩余的内容，不关闭底层的 io.Writer
+	Close() error
+}
+
+// NewWriter : 按 format 创建输出
+func NewWriter(format string, w io.Writer, cols []Column) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, cols)
+	case FormatJSONL:
+		return newJSONLWriter(w, cols), nil
+	case FormatParquet:
+		return newParquetWriter(w, cols), nil
+	}
+	return nil, fmt.Errorf("unknown format '%s', should be %s, %s or %s", format, FormatCSV, FormatJSONL, FormatParquet)
+}
+
+// Columns : table 的全部列
+func Columns(db *sql.DB, table string) ([]Column, error) {
+	rows, err := db.Query("SELECT * FROM " + table + " WHERE 1=0")
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	return columns(rows)
+}
+
+func columns(rows *sql.Rows) ([]Column, error) {
+	types, err := rows.ColumnTypes()
+	if nil != err {
+		return nil, err
+	}
+	cols := make([]Column, 0, len(types))
+	for _, t := range types {
+		cols = append(cols, Column{Name: t.Name(), Kind: KindOf(t.DatabaseTypeName())})
+	}
+	return cols, nil
+}
+
+// Query : 执行 query，把结果逐行按 format 写到 out，返回写出的行数
+func Query(db *sql.DB, format string, out io.Writer, query string, args ...interface{}) (int, error) {
+	rows, err := db.Query(query, args...)
+	if nil != err {
+		return 0, err
+	}
+	defer rows.Close()
+	cols, err := columns(rows)
+	if nil != err {
+		return 0, err
+	}
+	w, err := NewWriter(format, out, cols)
+	if nil != err {
+		return 0, err
+	}
+
+	raw := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range raw {
+		ptrs[i] = &raw[i]
+	}
+	vals := make([]interface{}, len(cols))
+	count := 0
+	for rows.Next() {
+		if err = rows.Scan(ptrs...); nil != err {
+			return count, err
+		}
+		for i, col := range cols {
+			if vals[i], err = convert(raw[i], col.Kind); nil != err {
+				return count, fmt.Errorf("row %d column %s : %v", count+1, col.Name, err)
+			}
+		}
+		if err = w.Write(vals); nil != err {
+			return count, err
+		}
+		count++
+		if count%100000 == 0 {
+			log.Infof("Query - %d rows exported", count)
+		}
+	}
+	if err = rows.Err(); nil != err {
+		return count, err
+	}
+	return count, w.Close()
+}
+
+// sqlite 以文本保存时间时可能的格式
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// convert : 把驱动返回的值转成 kind 对应的 int64/float64/bool/time.Time/string
+func convert(v interface{}, kind Kind) (interface{}, error) {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch kind {
+	case KindInt:
+		switch x := v.(type) {
+		case int64:
+			return x, nil
+		case float64:
+			return int64(x), nil
+		case bool:
+			if x {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case string:
+			return strconv.ParseInt(x, 10, 64)
+		}
+	case KindFloat:
+		switch x := v.(type) {
+		case float64:
+			return x, nil
+		case int64:
+			return float64(x), nil
+		case string:
+			return strconv.ParseFloat(x, 64)
+		}
+	case KindBool:
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case int64:
+			return x != 0, nil
+		case string:
+			return strconv.ParseBool(x)
+		}
+	case KindTime:
+		switch x := v.(type) {
+		case time.Time:
+			return x.UTC(), nil
+		case string:
+			for _, layout := range timeLayouts {
+				if tm, err := time.Parse(layout, x); nil == err {
+					return tm.UTC(), nil
+				}
+			}
+			return nil, fmt.Errorf("time '%s' unrecognized", x)
+		}
+	case KindString:
+		if tm, ok := v.(time.Time); ok {
+			return tm.UTC().Format(time.RFC3339), nil
+		}
+		return fmt.Sprint(v), nil
+	}
+	return nil, fmt.Errorf("value %v (%T) does not fit column type %d", v, v, kind)
+}
+
+// format : CSV 和 JSON Lines 中的文本形式
+func format(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
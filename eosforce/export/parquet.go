@@ -0,0 +1,96 @@
+package export
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetBatch : 攒够多少行交给 parquet 写一次
+const parquetBatch = 1024
+
+// parquetWriter : 每列都是 optional，NULL 写成空值
+type parquetWriter struct {
+	w     *parquet.Writer
+	cols  []Column
+	index []int // cols[i] 在 schema 中的列号，schema 按列名排序
+	rows  []parquet.Row
+}
+
+func parquetNode(kind Kind) parquet.Node {
+	switch kind {
+	case KindInt:
+		return parquet.Optional(parquet.Int(64))
+	case KindFloat:
+		return parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	case KindBool:
+		return parquet.Optional(parquet.Leaf(parquet.BooleanType))
+	case KindTime:
+		return parquet.Optional(parquet.Timestamp(parquet.Millisecond))
+	}
+	return parquet.Optional(parquet.String())
+}
+
+func newParquetWriter(w io.Writer, cols []Column) *parquetWriter {
+	group := make(parquet.Group, len(cols))
+	for _, col := range cols {
+		group[col.Name] = parquetNode(col.Kind)
+	}
+	schema := parquet.NewSchema("row", group)
+	pos := make(map[string]int)
+	for i, path := range schema.Columns() {
+		pos[strings.Join(path, ".")] = i
+	}
+	pw := &parquetWriter{w: parquet.NewWriter(w, schema), cols: cols, index: make([]int, len(cols))}
+	for i, col := range cols {
+		pw.index[i] = pos[col.Name]
+	}
+	return pw
+}
+
+func (pw *parquetWriter) Write(vals []interface{}) error {
+	row := make(parquet.Row, len(vals))
+	for i, v := range vals {
+		idx := pw.index[i]
+		var pv parquet.Value
+		switch x := v.(type) {
+		case nil:
+			row[idx] = parquet.NullValue().Level(0, 0, idx)
+			continue
+		case int64:
+			pv = parquet.Int64Value(x)
+		case float64:
+			pv = parquet.DoubleValue(x)
+		case bool:
+			pv = parquet.BooleanValue(x)
+		case time.Time:
+			pv = parquet.Int64Value(x.UnixMilli())
+		default:
+			pv = parquet.ByteArrayValue([]byte(format(x)))
+		}
+		row[idx] = pv.Level(0, 1, idx)
+	}
+	pw.rows = append(pw.rows, row)
+	if len(pw.rows) >= parquetBatch {
+		return pw.flush()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) flush() error {
+	if len(pw.rows) == 0 {
+		return nil
+	}
+	_, err := pw.w.WriteRows(pw.rows)
+	pw.rows = pw.rows[:0]
+	return err
+}
+
+func (pw *parquetWriter) Close() error {
+	if err := pw.flush(); nil != err {
+		return err
+	}
+	return pw.w.Close()
+}
@@ -0,0 +1,77 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// csvWriter : 第一行为列名
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer, cols []Column) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), record: make([]string, len(cols))}
+	for i, col := range cols {
+		cw.record[i] = col.Name
+	}
+	if err := cw.w.Write(cw.record); nil != err {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(vals []interface{}) error {
+	for i, v := range vals {
+		cw.record[i] = format(v)
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// jsonlWriter : 每行一个 json 对象，字段按列的顺序，NULL 为 null
+type jsonlWriter struct {
+	w    *bufio.Writer
+	keys [][]byte
+}
+
+func newJSONLWriter(w io.Writer, cols []Column) *jsonlWriter {
+	jw := &jsonlWriter{w: bufio.NewWriter(w), keys: make([][]byte, len(cols))}
+	for i, col := range cols {
+		jw.keys[i], _ = json.Marshal(col.Name)
+	}
+	return jw
+}
+
+func (jw *jsonlWriter) Write(vals []interface{}) error {
+	jw.w.WriteByte('{')
+	for i, v := range vals {
+		if i > 0 {
+			jw.w.WriteByte(',')
+		}
+		jw.w.Write(jw.keys[i])
+		jw.w.WriteByte(':')
+		if _, ok := v.(time.Time); ok {
+			v = format(v)
+		}
+		buf, err := json.Marshal(v)
+		if nil != err {
+			return err
+		}
+		jw.w.Write(buf)
+	}
+	jw.w.WriteByte('}')
+	return jw.w.WriteByte('\n')
+}
+
+func (jw *jsonlWriter) Close() error {
+	return jw.w.Flush()
+}
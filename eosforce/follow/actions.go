@@ -0,0 +1,123 @@
+package follow
+
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+// ActionRecord : ActionLog 保存的一条 action
+type ActionRecord struct {
+	SeqNum    uint64
+	BlockNum  uint64
+	BlockTime time.Time
+	TrxID     string
+	Account   string
+	Name      string
+	Data      string // action data 的原始 json，没有 ABI 时为 hex
+}
+
+// AddActionTables : 在dbmap上注册 ActionLog 表
+func AddActionTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(ActionRecord{}, "ActionLog").SetKeys(false, "SeqNum")
+}
+
+// ActionLog : 把匹配的 action 原样存入 ActionLog 表
+type ActionLog struct {
+	filters map[string]bool // account:name 或 account:*
+}
+
+// NewActionLog : filters 形如 "eosio.token:transfer,eosio:*"，为空则不记录任何 action
+func NewActionLog(filters string) *ActionLog {
+	al := &ActionLog{filters: make(map[string]bool)}
+	for _, f := range strings.Split(filters, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !strings.Contains(f, ":") {
+			f += ":*"
+		}
+		al.filters[f] = true
+	}
+	return al
+}
+
+// Empty : 没有任何过滤条件
+func (al *ActionLog) Empty() bool {
+	return len(al.filters) == 0
+}
+
+// Handle : 实现 Handler
+func (al *ActionLog) Handle(exec gorp.SqlExecutor, act *eosapi.Action) error {
+	a := &act.ActionTrace.Act
+	if !al.filters[a.Account+":"+a.Name] && !al.filters[a.Account+":*"] {
+		return nil
+	}
+	data := string(a.Data)
+	if len(a.Data) == 0 {
+		data = a.HexData
+	}
+	_, err := exec.Exec(store.UpsertSQL("ActionLog", []string{"SeqNum"}, "SeqNum", "BlockNum", "BlockTime", "TrxID", "Account", "Name", "Data"),
+		act.GlobalActionSeq, act.BlockNum, act.Time(), act.ActionTrace.TrxID, a.Account, a.Name, data)
+	return err
+}
+
+// DeltaRecord : DeltaLog 保存的一行表变化
+type DeltaRecord struct {
+	ID         int64
+	BlockNum   uint64
+	Present    bool
+	Code       string
+	Scope      string
+	TableName  string
+	PrimaryKey uint64
+	Payer      string
+	Value      string // hex
+}
+
+// AddDeltaTables : 在dbmap上注册 DeltaLog 表
+func AddDeltaTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(DeltaRecord{}, "DeltaLog").SetKeys(true, "ID")
+}
+
+// DeltaLog : 把匹配的合约表变化存入 DeltaLog 表，实现 Handler 和 DeltaHandler
+type DeltaLog struct {
+	filters map[string]bool // code:table 或 code:*
+}
+
+// NewDeltaLog : filters 形如 "eosio:voters,eosio.token:*"，为空则不记录
+func NewDeltaLog(filters string) *DeltaLog {
+	return &DeltaLog{filters: NewActionLog(filters).filters}
+}
+
+// Empty : 没有任何过滤条件
+func (dl *DeltaLog) Empty() bool {
+	return len(dl.filters) == 0
+}
+
+// Handle : DeltaLog 不处理 action
+func (dl *DeltaLog) Handle(exec gorp.SqlExecutor, act *eosapi.Action) error {
+	return nil
+}
+
+// HandleDelta : 实现 DeltaHandler
+func (dl *DeltaLog) HandleDelta(exec gorp.SqlExecutor, delta *TableDelta) error {
+	if !dl.filters[delta.Code+":"+delta.Table] && !dl.filters[delta.Code+":*"] {
+		return nil
+	}
+	return exec.Insert(&DeltaRecord{
+		BlockNum:   delta.BlockNum,
+		Present:    delta.Present,
+		Code:       delta.Code,
+		Scope:      delta.Scope,
+		TableName:  delta.Table,
+		PrimaryKey: delta.PrimaryKey,
+		Payer:      delta.Payer,
+		Value:      hex.EncodeToString(delta.Value),
+	})
+}
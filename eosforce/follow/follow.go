@@ -0,0 +1,253 @@
+// Package follow 逐个 block 跟随链，不依赖 history 插件。
+//
+// Follower 从起始块号开始用 get_block（或 trace_api）取不可逆的 block，
+// 把其中的 action 依次交给 Pipeline 中的 Handler 处理。每个 block 的处理结果和断点在同一个事务里提交，
+// 中途退出后从断点的下一个 block 继续，不会重复也不会遗漏。
+// state history 插件的客户端（ship 包）也把 block 送进同一个 Pipeline。
+package follow
+
+import (
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/budget"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/metrics"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+var log = logging.New("follow")
+
+// Handler : 处理一个 action，exec 是当前 block 的事务，返回错误时整个 block 回滚并重试
+type Handler interface {
+	Handle(exec gorp.SqlExecutor, act *eosapi.Action) error
+}
+
+// HandlerFunc : 把函数当作 Handler
+type HandlerFunc func(exec gorp.SqlExecutor, act *eosapi.Action) error
+
+// Handle : 实现 Handler
+func (fn HandlerFunc) Handle(exec gorp.SqlExecutor, act *eosapi.Action) error {
+	return fn(exec, act)
+}
+
+// TableDelta : state history 插件送来的一行合约表变化
+type TableDelta struct {
+	BlockNum   uint64
+	Present    bool // false 表示这一行被删除
+	Code       string
+	Scope      string
+	Table      string
+	PrimaryKey uint64
+	Payer      string
+	Value      []byte // 行的二进制内容，需按合约 ABI 解码
+}
+
+// DeltaHandler : 还要处理表变化的 Handler 实现它，只有 ship 包会送来表变化
+type DeltaHandler interface {
+	HandleDelta(exec gorp.SqlExecutor, delta *TableDelta) error
+}
+
+// BlockHandler : 还要按 block 处理的 Handler 实现它，在该 block 的 action 之前调用，空 block 也会调用
+type BlockHandler interface {
+	HandleBlock(exec gorp.SqlExecutor, block *eosapi.Block) error
+}
+
+// BlockPreparer : 处理 block 前要访问节点的 Handler 实现它，在该 block 的事务开始前调用，
+// 返回错误时整个 block 重试
+type BlockPreparer interface {
+	PrepareBlock(block *eosapi.Block) error
+}
+
+// Checkpoint : 一个 follower 已处理完的最后一个 block
+type Checkpoint struct {
+	Name      string
+	BlockNum  uint64
+	UpdatedAt time.Time
+}
+
+// AddTables : 在dbmap上注册 FollowCheckpoint 表
+func AddTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(Checkpoint{}, "FollowCheckpoint").SetKeys(false, "Name")
+}
+
+// Pipeline : 一组 Handler 和它们共同的断点
+type Pipeline struct {
+	Name     string // 断点名，同一个库里不同的 follower 用不同的名字
+	DB       *gorp.DbMap
+	Handlers []Handler
+}
+
+// LastBlock : 断点，没有断点返回0
+func (p *Pipeline) LastBlock() (uint64, error) {
+	num, err := p.DB.SelectInt("SELECT BlockNum FROM FollowCheckpoint WHERE Name=?", p.Name)
+	if nil != err {
+		return 0, err
+	}
+	return uint64(num), nil
+}
+
+// Next : 下一个要处理的 block，没有断点时为 start
+func (p *Pipeline) Next(start uint64) (uint64, error) {
+	last, err := p.LastBlock()
+	if nil != err {
+		log.Errorf("follow.Next - read checkpoint %s failed : %v", p.Name, err)
+		return 0, err
+	}
+	if last == 0 {
+		if start == 0 {
+			start = 1
+		}
+		return start, nil
+	}
+	if start > last+1 {
+		log.Infof("follow.Next - checkpoint %s at %d, ignore start %d", p.Name, last, start)
+	}
+	return last + 1, nil
+}
+
+// Apply : 在一个事务里处理 block 的全部 action 和表变化，并推进断点
+func (p *Pipeline) Apply(block *eosapi.Block, deltas []*TableDelta) error {
+	metrics.PageFetched(metrics.PageBlock)
+	start := time.Now()
+	for _, h := range p.Handlers {
+		if bp, ok := h.(BlockPreparer); ok {
+			if err := bp.PrepareBlock(block); nil != err {
+				return err
+			}
+		}
+	}
+	trans, err := p.DB.Begin()
+	if nil != err {
+		return err
+	}
+	for _, h := range p.Handlers {
+		bh, ok := h.(BlockHandler)
+		if !ok {
+			continue
+		}
+		if err = bh.HandleBlock(trans, block); nil != err {
+			trans.Rollback()
+			return err
+		}
+	}
+	for idx := range block.Actions {
+		act := &block.Actions[idx]
+		metrics.ActionProcessed(act.ActionTrace.Act.Account, act.ActionTrace.Act.Name)
+		for _, h := range p.Handlers {
+			if err = h.Handle(trans, act); nil != err {
+				trans.Rollback()
+				return err
+			}
+		}
+	}
+	for _, delta := range deltas {
+		for _, h := range p.Handlers {
+			dh, ok := h.(DeltaHandler)
+			if !ok {
+				continue
+			}
+			if err = dh.HandleDelta(trans, delta); nil != err {
+				trans.Rollback()
+				return err
+			}
+		}
+	}
+	if _, err = trans.Exec(store.UpsertSQL("FollowCheckpoint", []string{"Name"}, "Name", "BlockNum", "UpdatedAt"),
+		p.Name, block.Num, time.Now()); nil != err {
+		trans.Rollback()
+		return err
+	}
+	if err = trans.Commit(); nil != err {
+		return err
+	}
+	metrics.ObserveDB("block", start)
+	return nil
+}
+
+// Follower : 通过 HTTP 接口跟随链
+type Follower struct {
+	Pipeline
+	NodeURL  string
+	Start    uint64        // 没有断点时从哪个 block 开始
+	Traces   bool          // 用 trace_api 取 block，包含 inline action
+	Interval time.Duration // 追上不可逆块后，隔多久再查
+}
+
+func (f *Follower) getBlock(num uint64) (*eosapi.Block, error) {
+	if f.Traces {
+		return eosapi.GetTraceBlock(f.NodeURL, num)
+	}
+	return eosapi.GetBlock(f.NodeURL, num)
+}
+
+// Run : 一直跟随到 stop 关闭。只处理不可逆的 block，不需要处理分叉。
+func (f *Follower) Run(stop <-chan struct{}) error {
+	next, err := f.Next(f.Start)
+	if nil != err {
+		return err
+	}
+	log.Infof("follow.Run - %s from block %d", f.Name, next)
+
+	failures := 0
+	// 出错后退避，stop 关闭返回 false
+	wait := func(d time.Duration) bool {
+		select {
+		case <-time.After(d):
+			return true
+		case <-stop:
+			return false
+		}
+	}
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		info, err := eosapi.GetInfo(f.NodeURL)
+		if nil != err {
+			log.Warnf("follow.Run - eosapi.GetInfo failed : %v", err)
+			if !wait(budget.Backoff(failures)) {
+				return nil
+			}
+			failures++
+			continue
+		}
+		if next > info.LastIrreversibleBlockNum {
+			if !wait(f.Interval) {
+				return nil
+			}
+			continue
+		}
+
+		for ; next <= info.LastIrreversibleBlockNum; next++ {
+			select {
+			case <-stop:
+				return nil
+			default:
+			}
+			block, err := f.getBlock(next)
+			if nil == err {
+				err = f.Apply(block, nil)
+			}
+			if nil != err {
+				log.Warnf("follow.Run - block %d failed : %v", next, err)
+				break
+			}
+			failures = 0
+			metrics.SetLag(f.Name, next, info.HeadBlockNum, info.LastIrreversibleBlockNum)
+			if next%1000 == 0 {
+				log.Infof("follow.Run - %s at block %d, irreversible %d", f.Name, next, info.LastIrreversibleBlockNum)
+			}
+		}
+		if next <= info.LastIrreversibleBlockNum {
+			if !wait(budget.Backoff(failures)) {
+				return nil
+			}
+			failures++
+		}
+	}
+}
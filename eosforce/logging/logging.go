@@ -0,0 +1,164 @@
+// Package logging 是各工具共用的分级日志，底层是 log/slog。
+//
+// 每个包用 New 取一个带组件名的 Logger，级别可以按组件单独设置，如
+//
+//	-log_level info,accounts=debug,voters=warn
+//	-log_format json
+//
+// 级别从低到高为 debug、info、warn、error，逐行的明细只在 debug 输出。
+// 标准库 log 的输出也会转到这里，按 info 处理。
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	mu           sync.RWMutex
+	handler      slog.Handler = newHandler(os.Stderr, FormatText, slog.LevelInfo)
+	defaultLevel              = slog.LevelInfo
+	levels                    = map[string]slog.Level{} // 组件 -> 级别
+)
+
+// shortSource : 和 log.Lshortfile 一样只保留文件名
+func shortSource(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.SourceKey {
+		if src, ok := a.Value.Any().(*slog.Source); ok {
+			if src.File == "" { // 标准库 log 转过来的没有调用位置
+				return slog.Attr{}
+			}
+			return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", filepath.Base(src.File), src.Line))
+		}
+	}
+	return a
+}
+
+func newHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{AddSource: true, Level: level, ReplaceAttr: shortSource}
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// ParseLevel : 解析 debug/info/warn/error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); nil != err {
+		return 0, fmt.Errorf("unknown log level '%s'", s)
+	}
+	return level, nil
+}
+
+// ParseLevels : 解析 "info,voters=debug" 形式的级别设置，没有写默认级别时为 info
+func ParseLevels(spec string) (def slog.Level, components map[string]slog.Level, err error) {
+	def, components = slog.LevelInfo, map[string]slog.Level{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value := "", item
+		if idx := strings.Index(item, "="); idx >= 0 {
+			name, value = strings.TrimSpace(item[:idx]), strings.TrimSpace(item[idx+1:])
+		}
+		level, err := ParseLevel(value)
+		if nil != err {
+			return def, nil, err
+		}
+		if name == "" {
+			def = level
+		} else {
+			components[name] = level
+		}
+	}
+	return def, components, nil
+}
+
+// Setup : 设置输出、格式和级别，spec 格式见 ParseLevels
+func Setup(w io.Writer, format, spec string) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("unknown log format '%s', should be %s or %s", format, FormatText, FormatJSON)
+	}
+	def, components, err := ParseLevels(spec)
+	if nil != err {
+		return err
+	}
+	h := newHandler(w, format, def)
+	mu.Lock()
+	handler, defaultLevel, levels = h, def, components
+	mu.Unlock()
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// Logger : 一个组件的日志
+type Logger struct {
+	component string
+}
+
+// New : 取组件的 Logger，一般每个包一个
+func New(component string) *Logger {
+	return &Logger{component: component}
+}
+
+// Enabled : 该级别是否输出
+func (l *Logger) Enabled(level slog.Level) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	min, ok := levels[l.component]
+	if !ok {
+		min = defaultLevel
+	}
+	return level >= min
+}
+
+func (l *Logger) output(level slog.Level, format string, args ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // 跳过 Callers、output 和 Debugf 等
+	r := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, args...), pcs[0])
+	r.AddAttrs(slog.String("component", l.component))
+	mu.RLock()
+	h := handler
+	mu.RUnlock()
+	// 级别已按组件判断过，直接交给 handler，不再经过 handler 的默认级别
+	h.Handle(context.Background(), r)
+}
+
+// Debugf : 逐行明细等调试信息
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.output(slog.LevelDebug, format, args...)
+}
+
+// Infof : 进度和结果
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.output(slog.LevelInfo, format, args...)
+}
+
+// Warnf : 可以继续的异常，如跳过、重试
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.output(slog.LevelWarn, format, args...)
+}
+
+// Errorf : 导致当前操作失败的错误
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.output(slog.LevelError, format, args...)
+}
@@ -0,0 +1,121 @@
+// Package metrics 用 prometheus 统计各工具的进度，子命令加 -metrics 监听地址时在 /metrics 提供。
+//
+//	eosutils_pages_fetched_total{kind}                 读取的 history 分页、表分页和 block 数
+//	eosutils_actions_processed_total{contract,action}  处理过的 action
+//	eosutils_rpc_duration_seconds{path}                节点接口耗时
+//	eosutils_rpc_errors_total{path}                    节点接口出错次数
+//	eosutils_chain_lag_blocks{name,to}                 跟随进度落后 head/lib 的块数
+//	eosutils_db_write_duration_seconds{op}             写库耗时
+//	eosutils_transfers_total{status}                   转账 sent/failed 次数
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var log = logging.New("metrics")
+
+// PagesFetched 的 kind
+const (
+	PageHistory = "history"
+	PageTable   = "table"
+	PageBlock   = "block"
+)
+
+var (
+	pagesFetched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eosutils_pages_fetched_total",
+		Help: "History pages, table pages and blocks fetched from the node.",
+	}, []string{"kind"})
+	actionsProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eosutils_actions_processed_total",
+		Help: "Actions processed, by contract and action name.",
+	}, []string{"contract", "action"})
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "eosutils_rpc_duration_seconds",
+		Help:    "Latency of node RPC calls.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"path"})
+	rpcErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eosutils_rpc_errors_total",
+		Help: "Failed node RPC calls.",
+	}, []string{"path"})
+	chainLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "eosutils_chain_lag_blocks",
+		Help: "Blocks between the follower checkpoint and the chain head or LIB.",
+	}, []string{"name", "to"})
+	dbWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "eosutils_db_write_duration_seconds",
+		Help:    "Latency of database writes.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"op"})
+	transfers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eosutils_transfers_total",
+		Help: "Transfers pushed through cleos, by result.",
+	}, []string{"status"})
+)
+
+func init() {
+	prometheus.MustRegister(pagesFetched, actionsProcessed, rpcDuration, rpcErrors, chainLag, dbWriteDuration, transfers)
+}
+
+// Serve : 在 addr 上提供 /metrics，监听失败直接返回，之后在后台运行
+func Serve(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if nil != err {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.Serve(ln, mux); nil != err {
+			log.Infof("metrics.Serve - %s stopped : %v", addr, err)
+		}
+	}()
+	log.Infof("metrics.Serve - listening on %s/metrics", addr)
+	return nil
+}
+
+// PageFetched : 读取了一页，kind 见 Page* 常量
+func PageFetched(kind string) {
+	pagesFetched.WithLabelValues(kind).Inc()
+}
+
+// ActionProcessed : 处理了一个 action
+func ActionProcessed(contract, action string) {
+	actionsProcessed.WithLabelValues(contract, action).Inc()
+}
+
+// ObserveRPC : 记录从 start 开始的一次节点调用
+func ObserveRPC(path string, start time.Time, err error) {
+	rpcDuration.WithLabelValues(path).Observe(time.Since(start).Seconds())
+	if nil != err {
+		rpcErrors.WithLabelValues(path).Inc()
+	}
+}
+
+// ObserveDB : 记录从 start 开始的一次写库
+func ObserveDB(op string, start time.Time) {
+	dbWriteDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+// SetLag : name 已处理到 current，链的 head 和 lib 分别是多少
+func SetLag(name string, current, head, lib uint64) {
+	chainLag.WithLabelValues(name, "head").Set(float64(head) - float64(current))
+	chainLag.WithLabelValues(name, "lib").Set(float64(lib) - float64(current))
+}
+
+// Transfer : 记录一次转账结果
+func Transfer(err error) {
+	status := "sent"
+	if nil != err {
+		status = "failed"
+	}
+	transfers.WithLabelValues(status).Inc()
+}
@@ -0,0 +1,252 @@
+// Package monitor 跟踪出块顺序，统计每个 BP 每一轮应出和实出的块数。
+//
+// slot 长度和每个 BP 连续负责的 slot 数取自链的配置，eosio 为 0.5 秒 12 个，EOSForce 为 3 秒 1 个，
+// 一轮共 N*连续出块数 个 slot。
+// 相邻两个 block 之间空出的 slot 按出块顺序记为对应 BP 的漏块。
+// 一轮结束时检查关注的 BP，整轮没有出块或有漏块时报警；出块顺序变化导致它进出名单时也报警。
+// 出块顺序按 block 头里的版本取，节点已经不提供的旧版本（如从 -start 补历史时）不统计，只记一次日志。
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+var log = logging.New("monitor")
+
+// 报警类型
+const (
+	AlertMissedRound    = "missed_round"    // 整轮没有出块
+	AlertMissedBlocks   = "missed_blocks"   // 出了块但不足应出块数
+	AlertLeftSchedule   = "left_schedule"   // 被移出出块顺序
+	AlertJoinedSchedule = "joined_schedule" // 进入出块顺序
+)
+
+// Round : 一个 BP 在一轮中的出块统计
+type Round struct {
+	Version   uint32 // 出块顺序版本
+	Round     uint64 // slot / (N*连续出块数)，同一版本内唯一
+	Producer  string
+	Expected  int
+	Produced  int
+	UpdatedAt time.Time
+}
+
+// AddTables : 在dbmap上注册 ProducerRound 表
+func AddTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(Round{}, "ProducerRound").SetKeys(false, "Version", "Round", "Producer")
+}
+
+// Alert : 报警内容
+type Alert struct {
+	Kind      string `json:"event"`
+	Producer  string `json:"producer"`
+	Round     uint64 `json:"round"`
+	Version   uint32 `json:"schedule_version"`
+	Expected  int    `json:"expected"`
+	Produced  int    `json:"produced"`
+	BlockNum  uint64 `json:"block_num"`
+	BlockTime string `json:"block_time"`
+}
+
+func (a *Alert) String() string {
+	return fmt.Sprintf("%s %s round %d (schedule v%d) produced %d/%d @ block %d %s",
+		a.Kind, a.Producer, a.Round, a.Version, a.Produced, a.Expected, a.BlockNum, a.BlockTime)
+}
+
+// AlertFunc : 报警回调，exec 是当前 block 的事务
+type AlertFunc func(exec gorp.SqlExecutor, alert *Alert) error
+
+// Monitor : 按 block 统计出块，实现 follow.Handler、follow.BlockPreparer 和 follow.BlockHandler
+type Monitor struct {
+	NodeURL     string
+	BP          string        // 关注的 BP
+	Interval    time.Duration // 出块间隔，即 slot 长度
+	Repetitions int           // 每个 BP 每轮连续出块数
+	OnAlert     AlertFunc     // 可以为 nil
+
+	schedules  map[uint32][]string // 按版本缓存的出块顺序，节点取不到的版本为 nil
+	started    bool                // 已经处理过 block
+	schedule   []string            // 当前 block 的出块顺序，为 nil 时不统计
+	version    uint32
+	lastSlot   uint64 // 上一个 block 的 slot，0表示还没有
+	round      uint64
+	firstRound bool // 启动后的第一轮不完整，不报警
+}
+
+// Handle : Monitor 不处理 action
+func (m *Monitor) Handle(exec gorp.SqlExecutor, act *eosapi.Action) error {
+	return nil
+}
+
+// PrepareBlock : block 用的出块顺序版本还没有缓存时，在事务外向节点查询。
+// 节点只提供生效中和待生效的出块顺序，其它版本记为取不到，之后不再查询
+func (m *Monitor) PrepareBlock(block *eosapi.Block) error {
+	if _, ok := m.schedules[block.ScheduleVersion]; ok {
+		return nil
+	}
+	resp, err := eosapi.GetProducerSchedule(m.NodeURL)
+	if nil != err {
+		return err
+	}
+	if m.schedules == nil {
+		m.schedules = make(map[uint32][]string)
+	}
+	for _, s := range []*eosapi.ProducerSchedule{&resp.Active, resp.Pending} {
+		if s != nil && len(s.Producers) > 0 {
+			m.schedules[s.Version] = s.Names()
+		}
+	}
+	if _, ok := m.schedules[block.ScheduleVersion]; !ok {
+		log.Warnf("monitor - block %d uses schedule v%d, node is at v%d, blocks of v%d are not counted",
+			block.Num, block.ScheduleVersion, resp.Active.Version, block.ScheduleVersion)
+		m.schedules[block.ScheduleVersion] = nil
+	}
+	return nil
+}
+
+// switchSchedule : 切换到 block 的出块顺序，关注的 BP 进出名单时报警，前后有一个版本取不到时不报
+func (m *Monitor) switchSchedule(exec gorp.SqlExecutor, block *eosapi.Block) error {
+	names, ok := m.schedules[block.ScheduleVersion]
+	if !ok {
+		return fmt.Errorf("schedule v%d of block %d not prepared", block.ScheduleVersion, block.Num)
+	}
+	first, known := !m.started, m.schedule != nil
+	was, now := contains(m.schedule, m.BP), contains(names, m.BP)
+	m.started = true
+	m.schedule, m.version = names, block.ScheduleVersion
+	m.lastSlot, m.firstRound = 0, true
+	if names == nil {
+		return nil
+	}
+	log.Infof("monitor - schedule v%d : %v", m.version, names)
+
+	if first && !now {
+		log.Warnf("monitor - %s is not in schedule v%d", m.BP, m.version)
+	}
+	if first || !known || was == now {
+		return nil
+	}
+	kind := AlertJoinedSchedule
+	if was {
+		kind = AlertLeftSchedule
+	}
+	return m.alert(exec, &Alert{Kind: kind, Producer: m.BP, Version: m.version, BlockNum: block.Num, BlockTime: block.Timestamp})
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Monitor) alert(exec gorp.SqlExecutor, alert *Alert) error {
+	log.Warnf("ALERT : %s", alert)
+	if m.OnAlert == nil {
+		return nil
+	}
+	return m.OnAlert(exec, alert)
+}
+
+// scheduled : slot 应由谁出块
+func (m *Monitor) scheduled(slot uint64) (round uint64, producer string) {
+	reps := uint64(m.Repetitions)
+	n := uint64(len(m.schedule)) * reps
+	return slot / n, m.schedule[(slot%n)/reps]
+}
+
+// count : 给 round 中的 producer 加应出块数和实出块数
+func (m *Monitor) count(exec gorp.SqlExecutor, round uint64, producer string, expected, produced int) error {
+	if _, err := exec.Exec(store.InsertIgnoreSQL("ProducerRound", "Version", "Round", "Producer", "Expected", "Produced", "UpdatedAt"),
+		m.version, round, producer, 0, 0, time.Now()); nil != err {
+		return err
+	}
+	_, err := exec.Exec("UPDATE ProducerRound SET Expected=Expected+?, Produced=Produced+?, UpdatedAt=? WHERE Version=? AND Round=? AND Producer=?",
+		expected, produced, time.Now(), m.version, round, producer)
+	return err
+}
+
+// finishRound : 一轮结束，检查关注的 BP
+func (m *Monitor) finishRound(exec gorp.SqlExecutor, block *eosapi.Block) error {
+	if m.firstRound {
+		m.firstRound = false
+		return nil
+	}
+	if !contains(m.schedule, m.BP) {
+		return nil
+	}
+	var rows []*Round
+	if _, err := exec.Select(&rows, "SELECT * FROM ProducerRound WHERE Version=? AND Round=? AND Producer=?", m.version, m.round, m.BP); nil != err {
+		return err
+	}
+	if len(rows) == 0 || rows[0].Produced >= rows[0].Expected {
+		return nil
+	}
+	r := rows[0]
+	kind := AlertMissedBlocks
+	if r.Produced == 0 {
+		kind = AlertMissedRound
+	}
+	return m.alert(exec, &Alert{Kind: kind, Producer: m.BP, Round: r.Round, Version: r.Version,
+		Expected: r.Expected, Produced: r.Produced, BlockNum: block.Num, BlockTime: block.Timestamp})
+}
+
+// HandleBlock : 统计 block 和它之前空出的 slot，出块顺序取不到的 block 跳过
+func (m *Monitor) HandleBlock(exec gorp.SqlExecutor, block *eosapi.Block) error {
+	if !m.started || block.ScheduleVersion != m.version {
+		if err := m.switchSchedule(exec, block); nil != err {
+			return err
+		}
+	}
+	if m.schedule == nil {
+		return nil
+	}
+	slot := block.Slot(m.Interval)
+	if slot == 0 {
+		return fmt.Errorf("block %d has bad timestamp '%s'", block.Num, block.Timestamp)
+	}
+
+	from := slot
+	if m.lastSlot > 0 {
+		from = m.lastSlot + 1
+	}
+	for s := from; s <= slot; s++ {
+		round, producer := m.scheduled(s)
+		if m.lastSlot == 0 && s == from {
+			m.round = round
+		}
+		if round != m.round {
+			if err := m.finishRound(exec, block); nil != err {
+				return err
+			}
+			m.round = round
+		}
+		if s < slot {
+			if err := m.count(exec, round, producer, 1, 0); nil != err {
+				return err
+			}
+			continue
+		}
+		if producer != block.Producer {
+			log.Warnf("monitor - block %d slot %d produced by %s, schedule v%d says %s", block.Num, slot, block.Producer, m.version, producer)
+			if err := m.count(exec, round, producer, 1, 0); nil != err {
+				return err
+			}
+			if err := m.count(exec, round, block.Producer, 0, 1); nil != err {
+				return err
+			}
+		} else if err := m.count(exec, round, producer, 1, 1); nil != err {
+			return err
+		}
+	}
+	m.lastSlot = slot
+	return nil
+}
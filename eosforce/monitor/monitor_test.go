@@ -0,0 +1,186 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+// scheduleServer : 充当节点的 get_producer_schedule，只返回生效中的版本
+type scheduleServer struct {
+	schedules map[uint32][]string
+
+	mu     sync.Mutex
+	active uint32
+	calls  int
+}
+
+func (s *scheduleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/chain/get_producer_schedule" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	var resp eosapi.RespGetProducerSchedule
+	resp.Active.Version = s.active
+	for _, name := range s.schedules[s.active] {
+		resp.Active.Producers = append(resp.Active.Producers, struct {
+			ProducerName string `json:"producer_name"`
+		}{name})
+	}
+	json.NewEncoder(w).Encode(&resp)
+}
+
+// epoch : block 时间的起点，同 eosapi
+var epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testBlock : slot 上由 producer 出的块，nodeAt 为处理它时节点生效中的出块顺序版本
+type testBlock struct {
+	slot     uint64
+	producer string
+	version  uint32
+	nodeAt   uint32
+}
+
+// blocks : 按出块顺序连续出块，skip 中的 slot 空出
+func blocks(names []string, reps int, from, to uint64, version uint32, skip ...uint64) []testBlock {
+	var list []testBlock
+next:
+	for slot := from; slot <= to; slot++ {
+		for _, s := range skip {
+			if s == slot {
+				continue next
+			}
+		}
+		n := uint64(len(names) * reps)
+		list = append(list, testBlock{slot: slot, producer: names[(slot%n)/uint64(reps)], version: version, nodeAt: version})
+	}
+	return list
+}
+
+func TestRounds(t *testing.T) {
+	abc := []string{"alice", "bob", "carol"}
+	ab := []string{"alice", "bob"}
+	ac := []string{"alice", "carol"}
+	stale := blocks(abc, 1, 30, 39, 1, 34)
+	for idx := range stale {
+		stale[idx].nodeAt = 2
+	}
+
+	for _, c := range []struct {
+		name      string
+		reps      int
+		schedules map[uint32][]string
+		blocks    []testBlock
+		want      []Alert
+		calls     int
+	}{
+		{
+			name:      "missed round",
+			reps:      1,
+			schedules: map[uint32][]string{1: abc},
+			blocks:    blocks(abc, 1, 30, 39, 1, 34),
+			want:      []Alert{{Kind: AlertMissedRound, Producer: "bob", Round: 11, Version: 1, Expected: 1, Produced: 0}},
+			calls:     1,
+		},
+		{
+			name:      "missed blocks",
+			reps:      2,
+			schedules: map[uint32][]string{1: ab},
+			blocks:    blocks(ab, 2, 40, 52, 1, 47),
+			want:      []Alert{{Kind: AlertMissedBlocks, Producer: "bob", Round: 11, Version: 1, Expected: 2, Produced: 1}},
+			calls:     1,
+		},
+		{
+			name:      "first round not checked",
+			reps:      1,
+			schedules: map[uint32][]string{1: abc},
+			blocks:    blocks(abc, 1, 30, 35, 1, 31),
+			calls:     1,
+		},
+		{
+			name:      "left schedule",
+			reps:      1,
+			schedules: map[uint32][]string{1: abc, 2: ac},
+			blocks:    append(blocks(abc, 1, 30, 35, 1), blocks(ac, 1, 36, 41, 2)...),
+			want:      []Alert{{Kind: AlertLeftSchedule, Producer: "bob", Version: 2}},
+			calls:     2,
+		},
+		{
+			name:      "joined schedule",
+			reps:      1,
+			schedules: map[uint32][]string{1: ac, 2: abc},
+			blocks:    append(blocks(ac, 1, 30, 35, 1), blocks(abc, 1, 36, 41, 2)...),
+			want:      []Alert{{Kind: AlertJoinedSchedule, Producer: "bob", Version: 2}},
+			calls:     2,
+		},
+		{
+			// 从 -start 补历史时节点已经换了出块顺序，旧版本的 block 不统计，也不反复查询
+			name:      "stale schedule",
+			reps:      1,
+			schedules: map[uint32][]string{1: abc, 2: ac},
+			blocks:    stale,
+			calls:     1,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv := &scheduleServer{schedules: c.schedules}
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+
+			var alerts []Alert
+			m := &Monitor{NodeURL: ts.URL, BP: "bob", Interval: 3 * time.Second, Repetitions: c.reps,
+				OnAlert: func(exec gorp.SqlExecutor, alert *Alert) error {
+					a := *alert
+					a.BlockNum, a.BlockTime = 0, ""
+					alerts = append(alerts, a)
+					return nil
+				}}
+			dbmap := testDB(t)
+			for _, b := range c.blocks {
+				srv.mu.Lock()
+				srv.active = b.nodeAt
+				srv.mu.Unlock()
+				block := &eosapi.Block{
+					Num:             b.slot,
+					Timestamp:       epoch.Add(time.Duration(b.slot) * m.Interval).Format(eosapi.TimeLayout),
+					Producer:        b.producer,
+					ScheduleVersion: b.version,
+				}
+				if err := m.PrepareBlock(block); nil != err {
+					t.Fatal(err)
+				}
+				if err := m.HandleBlock(dbmap, block); nil != err {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(alerts, c.want) {
+				t.Errorf("alerts = %v, want %v", alerts, c.want)
+			}
+			if srv.calls != c.calls {
+				t.Errorf("get_producer_schedule called %d times, want %d", srv.calls, c.calls)
+			}
+		})
+	}
+}
+
+// testDB : 临时目录下的新 sqlite 库，已升级到最新版本
+func testDB(tb testing.TB) *gorp.DbMap {
+	dbmap, err := store.Open(filepath.Join(tb.TempDir(), "monitor.db"), AddTables)
+	if nil != err {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { dbmap.Db.Close() })
+	return dbmap
+}
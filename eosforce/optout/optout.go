@@ -0,0 +1,153 @@
+// Package optout 维护广播工具的退订名单。
+//
+// 收到广告的账号向发送账号转一笔带退订备注的转账（可以是0金额），
+// Scan 从发送账号的 history 中找出这些转账，把转出人记入 OptOut 表，
+// 之后 broadcast / broadcast.sqlite 都不会再向其发送。
+package optout
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+var log = logging.New("optout")
+
+// DefaultKeywords : 默认的退订备注关键字，逗号分隔，不区分大小写
+const DefaultKeywords = "unsubscribe,退订"
+
+// OptOut : 一条退订记录
+type OptOut struct {
+	Account   string    // 退订的账号
+	Memo      string    // 退订转账的备注
+	SeqNum    uint64    // 退订转账的 global_action_seq，手工添加的为0
+	BlockNum  uint64    // 退订转账所在block
+	BlockTime time.Time // 退订时间
+}
+
+// ScanState : 每个发送账号已扫描到的 account_action_seq，下次从其后继续
+type ScanState struct {
+	Sender  string
+	LastSeq int64
+}
+
+// AddTables : 在dbmap上注册 OptOut 和 OptOutScan 表，调用方随后需执行 CreateTablesIfNotExists
+func AddTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(OptOut{}, "OptOut").SetKeys(false, "Account")
+	dbmap.AddTableWithName(ScanState{}, "OptOutScan").SetKeys(false, "Sender")
+}
+
+// IsSuppressed : account 是否已退订
+func IsSuppressed(dbmap *gorp.DbMap, account string) (bool, error) {
+	cnt, err := dbmap.SelectInt("SELECT count(*) FROM OptOut WHERE Account=?", account)
+	if nil != err {
+		log.Errorf("IsSuppressed - query %s failed : %v", account, err)
+		return false, err
+	}
+	return cnt > 0, nil
+}
+
+// Add : 记录一条退订，已存在的保留最早的那条，inserted 表示确实新增了
+func Add(dbmap *gorp.DbMap, info *OptOut) (inserted bool, err error) {
+	res, err := dbmap.Exec(store.InsertIgnoreSQL("OptOut", "Account", "Memo", "SeqNum", "BlockNum", "BlockTime"),
+		info.Account, info.Memo, info.SeqNum, info.BlockNum, info.BlockTime)
+	if nil != err {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ParseKeywords : 把逗号分隔的关键字转成小写列表，忽略空项
+func ParseKeywords(s string) []string {
+	var kws []string
+	for _, kw := range strings.Split(s, ",") {
+		kw = strings.ToLower(strings.TrimSpace(kw))
+		if kw != "" {
+			kws = append(kws, kw)
+		}
+	}
+	return kws
+}
+
+// IsOptOutMemo : memo 中是否含有任一退订关键字
+func IsOptOutMemo(memo string, keywords []string) bool {
+	memo = strings.ToLower(memo)
+	for _, kw := range keywords {
+		if strings.Contains(memo, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan : 扫描 sender 收到的 token 合约的转账，把备注含退订关键字的转出人加入退订名单，其它合约的同名 action 不算。
+// nodeURL 形如 https://w1.eosforce.cn ，进度保存在 OptOutScan 表中，返回新增的退订数，已在名单中的不计。
+func Scan(dbmap *gorp.DbMap, nodeURL, token, sender string, keywords []string) (added int, err error) {
+	state := ScanState{Sender: sender, LastSeq: -1}
+	err = dbmap.SelectOne(&state, "SELECT * FROM OptOutScan WHERE Sender=?", sender)
+	if err == sql.ErrNoRows {
+		state = ScanState{Sender: sender, LastSeq: -1}
+	} else if nil != err {
+		log.Errorf("Scan - read progress of %s failed : %v", sender, err)
+		return 0, err
+	}
+
+	const offset = 99
+	for {
+		respActs, err := eosapi.GetActions(nodeURL, sender, state.LastSeq+1, offset)
+		if nil != err {
+			return added, err
+		}
+		lastSeq := state.LastSeq
+		for idx := range respActs.Actions {
+			act := &respActs.Actions[idx]
+			if act.AccountActionSeq <= state.LastSeq {
+				continue
+			}
+			state.LastSeq = act.AccountActionSeq
+
+			if act.ActionTrace.Act.Account != token {
+				continue
+			}
+			data := act.Transfer()
+			if data == nil {
+				continue
+			}
+			if data.To != sender || data.From == sender || !IsOptOutMemo(data.Memo, keywords) {
+				continue
+			}
+
+			inserted, err := Add(dbmap, &OptOut{
+				Account:   data.From,
+				Memo:      data.Memo,
+				SeqNum:    act.GlobalActionSeq,
+				BlockNum:  act.BlockNum,
+				BlockTime: act.Time(),
+			})
+			if nil != err {
+				log.Errorf("Scan - Add %s failed : %v", data.From, err)
+				return added, err
+			}
+			if !inserted {
+				continue
+			}
+			log.Infof("Scan - %s opted out by memo '%s'", data.From, data.Memo)
+			added++
+		}
+		if state.LastSeq == lastSeq { // 没有新的action
+			break
+		}
+
+		if _, err = dbmap.Exec(store.UpsertSQL("OptOutScan", []string{"Sender"}, "Sender", "LastSeq"), state.Sender, state.LastSeq); nil != err {
+			log.Errorf("Scan - save progress failed : %v", err)
+			return added, err
+		}
+	}
+	return added, nil
+}
@@ -0,0 +1,124 @@
+package optout
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+// testDB : 临时目录下的新 sqlite 库，已升级到最新版本
+func testDB(tb testing.TB) *gorp.DbMap {
+	dbmap, err := store.Open(filepath.Join(tb.TempDir(), "optout.db"), AddTables)
+	if nil != err {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { dbmap.Db.Close() })
+	return dbmap
+}
+
+// historyServer : 充当 history 插件，按 pos/offset 从 actions 中往后切片
+type historyServer struct {
+	actions []map[string]interface{}
+
+	mu   sync.Mutex
+	poss []int64 // 收到的 pos
+}
+
+func (h *historyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Pos    string `json:"pos"`
+		Offset string `json:"offset"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); nil != err || r.URL.Path != "/v1/history/get_actions" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	pos, _ := strconv.ParseInt(req.Pos, 10, 64)
+	offset, _ := strconv.ParseInt(req.Offset, 10, 64)
+	h.mu.Lock()
+	h.poss = append(h.poss, pos)
+	h.mu.Unlock()
+
+	acts := []map[string]interface{}{}
+	for seq := pos; seq <= pos+offset && seq < int64(len(h.actions)); seq++ {
+		acts = append(acts, h.actions[seq])
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"actions": acts})
+}
+
+// transfer : sender 的 history 里的一条 transfer，收到的转账是发给 sender 的通知
+func transfer(seq int64, contract, from, to, memo string) map[string]interface{} {
+	return map[string]interface{}{
+		"account_action_seq": seq,
+		"global_action_seq":  1000 + seq,
+		"block_num":          100 + seq,
+		"block_time":         "2024-01-01T00:00:00.000",
+		"action_trace": map[string]interface{}{
+			"receipt": map[string]interface{}{"receiver": "sender", "global_sequence": 1000 + seq},
+			"act": map[string]interface{}{
+				"account": contract,
+				"name":    "transfer",
+				"data":    map[string]string{"from": from, "to": to, "quantity": "0.0001 EOS", "memo": memo},
+			},
+		},
+	}
+}
+
+func TestScan(t *testing.T) {
+	h := &historyServer{actions: []map[string]interface{}{
+		transfer(0, "eosio.token", "bob", "sender", "Unsubscribe please"),
+		transfer(1, "fake.token", "carol", "sender", "unsubscribe"), // 其它合约的同名 action 不算
+		transfer(2, "eosio.token", "dave", "sender", "hello"),
+		transfer(3, "eosio.token", "sender", "erin", "unsubscribe"), // 转出的不算
+		transfer(4, "eosio.token", "bob", "sender", "退订"),           // 已在名单中
+		transfer(5, "eosio.token", "frank", "sender", "退订"),
+	}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	dbmap := testDB(t)
+	keywords := ParseKeywords(DefaultKeywords)
+
+	added, err := Scan(dbmap, srv.URL, "eosio.token", "sender", keywords)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if added != 2 {
+		t.Errorf("added %d, want 2", added)
+	}
+	var names []string
+	if _, err = dbmap.Select(&names, "SELECT Account FROM OptOut ORDER BY Account"); nil != err {
+		t.Fatal(err)
+	}
+	if want := []string{"bob", "frank"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("opted out %v, want %v", names, want)
+	}
+
+	// 再扫描从保存的进度之后开始
+	h.poss = nil
+	if added, err = Scan(dbmap, srv.URL, "eosio.token", "sender", keywords); nil != err || added != 0 {
+		t.Errorf("rescan added %d, err %v", added, err)
+	}
+	if want := []int64{6}; !reflect.DeepEqual(h.poss, want) {
+		t.Errorf("rescan requested pos %v, want %v", h.poss, want)
+	}
+
+	// 读进度出错时返回错误，不能从头重扫
+	h.poss = nil
+	if _, err = dbmap.Exec("DROP TABLE OptOutScan"); nil != err {
+		t.Fatal(err)
+	}
+	if _, err = Scan(dbmap, srv.URL, "eosio.token", "sender", keywords); nil == err {
+		t.Error("Scan should fail when progress can not be read")
+	}
+	if len(h.poss) != 0 {
+		t.Errorf("Scan requested %v after a db error", h.poss)
+	}
+}
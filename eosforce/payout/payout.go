@@ -0,0 +1,180 @@
+// Package payout 维护投票分红的付款表。
+//
+// getvoters 根据 VoteInfo 算出每个投票人应得的金额写入 Payout 表，
+// broadcast.sqlite -mode payout 逐行转账，并把 trx_id、block_num、状态写回。
+package payout
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/metrics"
+)
+
+var log = logging.New("payout")
+
+// 付款状态
+const (
+	StatusPending = "pending" // 尚未发送
+	StatusSending = "sending" // 已交给cleos，结果未知，重试前必须查链上记录
+	StatusSent    = "sent"    // cleos返回成功，已记录trx_id，等待确认
+	StatusFailed  = "failed"  // cleos返回失败或交易执行失败，重试前同样查链上记录
+	StatusExpired = "expired" // 交易已过期且查不到，history 可能还没同步，重发前同样查链上记录
+
+	StatusIrreversible = "irreversible" // 转账所在block已不可逆
+)
+
+// Payout : 一笔待付款
+type Payout struct {
+	ID        int64
+	Round     string    // 分红轮次，同一轮里每个收款人只有一行
+	Account   string    // 收款人
+	Amount    uint64    // 金额，单位为核心币的最小单位
+	Memo      string    // 转账备注
+	Status    string    // 见 Status* 常量
+	TrxID     string    // 转账的 transaction id
+	BlockNum  uint64    // 转账所在block
+	Attempts  int       // 已尝试次数
+	UpdatedAt time.Time // 最后一次状态变化时间
+}
+
+// Share : 计算分红用的投票人权重
+type Share struct {
+	Account string
+	Weight  uint64
+}
+
+// Receipt : 链上找到的转账记录
+type Receipt struct {
+	TrxID    string
+	BlockNum uint64
+}
+
+// AddTables : 在dbmap上注册 Payout 表，调用方随后需执行 CreateTablesIfNotExists
+func AddTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(Payout{}, "Payout").SetKeys(true, "ID")
+}
+
+// Plan : 按权重把 total 分给 shares，用整数计算，取整舍去的零头按余数从大到小每人补一个最小单位，
+// 合计正好是 total。权重为0的不分，分到0的不生成付款
+func Plan(shares []Share, total uint64, round, memo string) []*Payout {
+	sum := new(big.Int)
+	for _, s := range shares {
+		sum.Add(sum, new(big.Int).SetUint64(s.Weight))
+	}
+	if sum.Sign() == 0 {
+		return nil
+	}
+
+	sorted := make([]Share, len(shares))
+	copy(sorted, shares)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Account < sorted[j].Account })
+
+	amounts := make([]uint64, len(sorted))
+	rems := make([]*big.Int, len(sorted))
+	left := total
+	bigTotal := new(big.Int).SetUint64(total)
+	for idx, s := range sorted {
+		quo, rem := new(big.Int).QuoRem(new(big.Int).Mul(bigTotal, new(big.Int).SetUint64(s.Weight)), sum, new(big.Int))
+		amounts[idx], rems[idx] = quo.Uint64(), rem
+		left -= amounts[idx]
+	}
+	// 零头小于余数不为0的人数，每人最多补一个单位
+	order := make([]int, len(sorted))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(i, j int) bool { return rems[order[i]].Cmp(rems[order[j]]) > 0 })
+	for _, idx := range order[:left] {
+		amounts[idx]++
+	}
+
+	var payouts []*Payout
+	for idx, s := range sorted {
+		if amounts[idx] == 0 {
+			continue
+		}
+		payouts = append(payouts, &Payout{
+			Round:     round,
+			Account:   s.Account,
+			Amount:    amounts[idx],
+			Memo:      memo,
+			Status:    StatusPending,
+			UpdatedAt: time.Now(),
+		})
+	}
+	return payouts
+}
+
+// Save : 在一个事务里写入新生成的付款，失败时一条都不写。
+// 同一轮次已经生成过付款时拒绝写入，避免重复运行导致重复分红。
+func Save(dbmap *gorp.DbMap, payouts []*Payout) error {
+	trans, err := dbmap.Begin()
+	if nil != err {
+		return err
+	}
+	rounds := make(map[string]bool)
+	for _, p := range payouts {
+		if rounds[p.Round] {
+			continue
+		}
+		rounds[p.Round] = true
+		n, err := trans.SelectInt("SELECT count(*) FROM Payout WHERE Round=?", p.Round)
+		if nil != err {
+			log.Errorf("payout.Save - count round %s failed : %v", p.Round, err)
+			trans.Rollback()
+			return err
+		}
+		if n > 0 {
+			trans.Rollback()
+			return fmt.Errorf("payout round '%s' already planned with %d rows", p.Round, n)
+		}
+	}
+	for _, p := range payouts {
+		if err = trans.Insert(p); nil != err {
+			log.Errorf("payout.Save - insert %s failed : %v", p.Account, err)
+			trans.Rollback()
+			return err
+		}
+	}
+	return trans.Commit()
+}
+
+// SetStatus : 更新一行的状态和收据，exec 可以是事务
+func SetStatus(exec gorp.SqlExecutor, p *Payout) error {
+	p.UpdatedAt = time.Now()
+	defer metrics.ObserveDB("payout", p.UpdatedAt)
+	_, err := exec.Exec("UPDATE Payout SET Status=?, TrxID=?, BlockNum=?, Attempts=?, UpdatedAt=? WHERE ID=?",
+		p.Status, p.TrxID, p.BlockNum, p.Attempts, p.UpdatedAt, p.ID)
+	return err
+}
+
+// Key : 链上转账的匹配键
+func Key(to, quantity, memo string) string {
+	return strings.Join([]string{to, quantity, memo}, "\x00")
+}
+
+// FindOnChain : 从最新往前扫描 sender 在 since 之后转出的 token 合约的转账，返回 Key -> 全部匹配的 Receipt，从新到旧。
+// 其它合约的同名 action 和通知副本不算。收款人、金额、备注都相同的付款可能有多笔，调用方每行只能用掉其中一笔。
+func FindOnChain(nodeURL, token, sender string, since time.Time) (map[string][]Receipt, error) {
+	found := make(map[string][]Receipt)
+	err := eosapi.WalkBackward(nodeURL, sender, func(act *eosapi.Action) bool {
+		if tm := act.Time(); !tm.IsZero() && tm.Before(since) {
+			return false
+		}
+		data := act.TokenTransfer(token)
+		if data == nil || data.From != sender {
+			return true
+		}
+		key := Key(data.To, data.Quantity, data.Memo)
+		found[key] = append(found[key], Receipt{TrxID: act.ActionTrace.TrxID, BlockNum: act.BlockNum})
+		return true
+	})
+	return found, err
+}
@@ -0,0 +1,101 @@
+package payout
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testAction : get_actions 返回的一条 transfer
+func testAction(seq int64, contract, receiver, trxID, from, to, quantity, memo string, tm time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"account_action_seq": seq,
+		"global_action_seq":  1000 + seq,
+		"block_num":          100 + seq,
+		"block_time":         tm.Format("2006-01-02T15:04:05.000"),
+		"action_trace": map[string]interface{}{
+			"receipt": map[string]interface{}{"receiver": receiver, "global_sequence": 1000 + seq},
+			"trx_id":  trxID,
+			"act": map[string]interface{}{
+				"account": contract,
+				"name":    "transfer",
+				"data":    map[string]string{"from": from, "to": to, "quantity": quantity, "memo": memo},
+			},
+		},
+	}
+}
+
+func TestFindOnChain(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return since.Add(time.Duration(minutes) * time.Minute) }
+	const token, sender = "eosio.token", "payer"
+	actions := []map[string]interface{}{
+		testAction(0, token, token, "old", sender, "alice", "1.0000 EOS", "round", at(-5)),
+		testAction(1, token, token, "trx1", sender, "alice", "1.0000 EOS", "round", at(1)),
+		testAction(2, token, sender, "trx1", sender, "alice", "1.0000 EOS", "round", at(1)), // 通知副本
+		testAction(3, "fake.token", "fake.token", "fake", sender, "alice", "1.0000 EOS", "round", at(2)),
+		testAction(4, token, sender, "incoming", "bob", sender, "1.0000 EOS", "round", at(3)),
+		testAction(5, token, token, "trx2", sender, "alice", "1.0000 EOS", "round", at(4)),
+		testAction(6, token, token, "trx3", sender, "carol", "2.0000 EOS", "round", at(5)),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/history/get_actions" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"actions": actions})
+	}))
+	defer srv.Close()
+
+	found, err := FindOnChain(srv.URL, token, sender, since)
+	if nil != err {
+		t.Fatal(err)
+	}
+	want := map[string][]Receipt{
+		Key("alice", "1.0000 EOS", "round"): {{TrxID: "trx2", BlockNum: 105}, {TrxID: "trx1", BlockNum: 101}},
+		Key("carol", "2.0000 EOS", "round"): {{TrxID: "trx3", BlockNum: 106}},
+	}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("FindOnChain = %v, want %v", found, want)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		shares []Share
+		total  uint64
+	}{
+		{"even", []Share{{"a", 1}, {"b", 1}, {"c", 1}}, 100},
+		{"uneven", []Share{{"a", 7}, {"b", 3}, {"c", 5}, {"d", 11}}, 1000001},
+		{"large total", []Share{{"a", 1}, {"b", 2}}, 1<<63 + 12345},
+		{"large weights", []Share{{"a", 1 << 62}, {"b", 1<<62 - 1}, {"c", 3}}, 1<<53 + 1},
+		{"dust", []Share{{"a", 1}, {"b", 1000000}}, 999},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var sum uint64
+			for _, p := range Plan(c.shares, c.total, "r1", "memo") {
+				if p.Amount == 0 {
+					t.Errorf("zero payout to %s", p.Account)
+				}
+				sum += p.Amount
+			}
+			if sum != c.total {
+				t.Errorf("payouts sum %d, want total %d", sum, c.total)
+			}
+		})
+	}
+}
+
+func ExamplePlan() {
+	for _, p := range Plan([]Share{{"alice", 2}, {"bob", 1}, {"carol", 0}}, 100, "r1", "memo") {
+		fmt.Println(p.Account, p.Amount)
+	}
+	// Output:
+	// alice 67
+	// bob 33
+}
@@ -0,0 +1,165 @@
+package ship
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+)
+
+// decoder : 按 eosio ABI 的二进制格式顺序读取，出错后后续读取都返回零值，最后检查 err
+type decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func newDecoder(buf []byte) *decoder {
+	return &decoder{buf: buf}
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.pos+n > len(d.buf) {
+		d.err = fmt.Errorf("need %d bytes at %d, only %d left", n, d.pos, len(d.buf)-d.pos)
+		return nil
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) u8() uint8 {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) boolean() bool {
+	return d.u8() != 0
+}
+
+func (d *decoder) u16() uint16 {
+	b := d.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (d *decoder) u32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (d *decoder) u64() uint64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (d *decoder) i64() int64 {
+	return int64(d.u64())
+}
+
+func (d *decoder) varuint32() uint32 {
+	var v uint32
+	for shift := uint(0); shift < 35; shift += 7 {
+		b := d.u8()
+		v |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	if d.err == nil {
+		d.err = fmt.Errorf("varuint32 too long at %d", d.pos)
+	}
+	return 0
+}
+
+func (d *decoder) bytes() []byte {
+	return d.next(int(d.varuint32()))
+}
+
+func (d *decoder) str() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) name() string {
+	return eosapi.NameToStr(d.u64())
+}
+
+func (d *decoder) checksum256() string {
+	return fmt.Sprintf("%x", d.next(32))
+}
+
+// optional : 读 optional 的标志位
+func (d *decoder) optional() bool {
+	return d.boolean()
+}
+
+// asset : int64 数量 + symbol（低8位是精度，其余是币种字符）
+func (d *decoder) asset() string {
+	amount := d.i64()
+	sym := d.u64()
+	precision := int(sym & 0xff)
+	var code []byte
+	for sym >>= 8; sym > 0; sym >>= 8 {
+		code = append(code, byte(sym&0xff))
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return sign + eosapi.FormatAsset(uint64(amount), precision, string(code))
+}
+
+// signature : 类型 + 内容，K1/R1 是65字节，WebAuthn 还带 auth_data 和 client_json
+func (d *decoder) signature() {
+	typ := d.varuint32()
+	d.next(65)
+	if typ == 2 {
+		d.bytes()
+		d.str()
+	}
+}
+
+// encoder : 按 eosio ABI 的二进制格式写请求
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) u8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) boolean(v bool) {
+	if v {
+		e.u8(1)
+	} else {
+		e.u8(0)
+	}
+}
+
+func (e *encoder) u32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) varuint32(v uint32) {
+	for v >= 0x80 {
+		e.u8(uint8(v) | 0x80)
+		v >>= 7
+	}
+	e.u8(uint8(v))
+}
@@ -0,0 +1,82 @@
+package ship
+
+import (
+	"encoding/json"
+)
+
+// actionDecoders : 已知 action 的二进制 data 解码，结果和 history 插件返回的 json data 字段一致。
+// state history 插件不带合约 ABI，未列出的 action 只有 hex_data。
+var actionDecoders = map[string]func(d *decoder) interface{}{
+	"voteproducer": func(d *decoder) interface{} {
+		v := struct {
+			Voter     string   `json:"voter"`
+			Proxy     string   `json:"proxy"`
+			Producers []string `json:"producers"`
+		}{Voter: d.name(), Proxy: d.name()}
+		for n := d.varuint32(); n > 0 && d.err == nil; n-- {
+			v.Producers = append(v.Producers, d.name())
+		}
+		return v
+	},
+	"delegatebw": func(d *decoder) interface{} {
+		return struct {
+			From     string `json:"from"`
+			Receiver string `json:"receiver"`
+			StakeNet string `json:"stake_net_quantity"`
+			StakeCPU string `json:"stake_cpu_quantity"`
+			Transfer bool   `json:"transfer"`
+		}{d.name(), d.name(), d.asset(), d.asset(), d.boolean()}
+	},
+	"undelegatebw": func(d *decoder) interface{} {
+		return struct {
+			From       string `json:"from"`
+			Receiver   string `json:"receiver"`
+			UnstakeNet string `json:"unstake_net_quantity"`
+			UnstakeCPU string `json:"unstake_cpu_quantity"`
+		}{d.name(), d.name(), d.asset(), d.asset()}
+	},
+	// EOSForce 的投票
+	"vote": func(d *decoder) interface{} {
+		return struct {
+			Voter  string `json:"voter"`
+			BPName string `json:"bpname"`
+			Stake  string `json:"stake"`
+		}{d.name(), d.name(), d.asset()}
+	},
+	// EOSForce 的解冻和领取分红，data 相同
+	"unfreeze": voterBP,
+	"claim":    voterBP,
+	"transfer": func(d *decoder) interface{} {
+		return struct {
+			From     string `json:"from"`
+			To       string `json:"to"`
+			Quantity string `json:"quantity"`
+			Memo     string `json:"memo"`
+		}{d.name(), d.name(), d.asset(), d.str()}
+	},
+}
+
+func voterBP(d *decoder) interface{} {
+	return struct {
+		Voter  string `json:"voter"`
+		BPName string `json:"bpname"`
+	}{d.name(), d.name()}
+}
+
+// decodeActionData : 按 action 名解码 data，transfer 以外只认 eosio 合约的，解不了返回 nil
+func decodeActionData(account, name string, data []byte) json.RawMessage {
+	fn, ok := actionDecoders[name]
+	if !ok || (account != "eosio" && name != "transfer") {
+		return nil
+	}
+	d := newDecoder(data)
+	v := fn(d)
+	if d.err != nil || d.pos != len(data) {
+		return nil
+	}
+	buf, err := json.Marshal(v)
+	if nil != err {
+		return nil
+	}
+	return buf
+}
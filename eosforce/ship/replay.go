@@ -0,0 +1,105 @@
+package ship
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/websocket"
+)
+
+// 录制文件中每帧的格式：1字节消息类型 + 4字节小端长度 + 内容
+
+// record : 录下一帧，没有设置 Record 时什么都不做
+func (c *Client) record(typ int, frame []byte) error {
+	if c.Record == nil {
+		return nil
+	}
+	return writeFrame(c.Record, typ, frame)
+}
+
+func writeFrame(w io.Writer, typ int, frame []byte) error {
+	var head [5]byte
+	head[0] = byte(typ)
+	binary.LittleEndian.PutUint32(head[1:], uint32(len(frame)))
+	if _, err := w.Write(head[:]); nil != err {
+		return err
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader) (typ int, frame []byte, err error) {
+	var head [5]byte
+	if _, err = io.ReadFull(r, head[:]); nil != err {
+		return 0, nil, err
+	}
+	frame = make([]byte, binary.LittleEndian.Uint32(head[1:]))
+	if _, err = io.ReadFull(r, frame); nil != err {
+		return 0, nil, err
+	}
+	return int(head[0]), frame, nil
+}
+
+// ReplayHandler : 充当 state history 插件的 websocket 服务端，按顺序回放 path 中录好的帧。
+// 第一帧(ABI)连接后立即发送，其余的帧在收到客户端的请求后发送，客户端的 ack 读取后丢弃。
+// 回放不理会请求中的起始块号，客户端会校验块号是否连续。
+func ReplayHandler(path string) http.Handler {
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, err := os.Open(path)
+		if nil != err {
+			log.Errorf("ship.Replay - open %s failed : %v", path, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if nil != err {
+			log.Errorf("ship.Replay - upgrade failed : %v", err)
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(file)
+		typ, frame, err := readFrame(reader)
+		if nil != err {
+			log.Errorf("ship.Replay - read first frame failed : %v", err)
+			return
+		}
+		if err = conn.WriteMessage(typ, frame); nil != err {
+			return
+		}
+		if _, _, err = conn.ReadMessage(); nil != err {
+			return
+		}
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); nil != err {
+					return
+				}
+			}
+		}()
+
+		sent := 0
+		for {
+			typ, frame, err = readFrame(reader)
+			if err == io.EOF {
+				log.Infof("ship.Replay - replayed %d frames", sent)
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "replay done"))
+				return
+			}
+			if nil != err {
+				log.Errorf("ship.Replay - read frame failed : %v", err)
+				return
+			}
+			if err = conn.WriteMessage(typ, frame); nil != err {
+				log.Errorf("ship.Replay - write frame failed : %v", err)
+				return
+			}
+			sent++
+		}
+	})
+}
@@ -0,0 +1,177 @@
+// Package ship 是 nodeos state_history_plugin 的 websocket 客户端。
+//
+// 连接后服务端先发一帧 ABI 文本，客户端发 get_blocks_request_v0，
+// 服务端逐个 block 返回二进制的 get_blocks_result_v0，客户端每处理完一帧回一个 ack。
+// 解出的 action 和合约表变化送进 follow.Pipeline，和 follow 命令共用 Handler 与断点。
+// 只请求不可逆的 block，不处理分叉。支持 nodeos 2.0 的 v0 协议。
+//
+// 设置 Client.Record 可以把收到的帧录下来，ReplayHandler 用录好的帧充当服务端，
+// 不连节点也能重现一段数据。
+package ship
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gpmn/eosutils/eosforce/budget"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/follow"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/metrics"
+)
+
+var log = logging.New("ship")
+
+// Client : state history 插件客户端的参数
+type Client struct {
+	URL      string // 如 ws://127.0.0.1:8080
+	Start    uint64 // 没有断点时从哪个 block 开始
+	End      uint64 // 处理到哪个 block 为止(不含)，0表示一直跟随
+	Deltas   bool   // 是否请求表变化
+	InFlight uint32 // 未 ack 的最多帧数
+	Record   io.Writer
+}
+
+// request : 编码 get_blocks_request_v0
+func (c *Client) request(start uint64) []byte {
+	end := uint64(math.MaxUint32)
+	if c.End > 0 {
+		end = c.End
+	}
+	inFlight := c.InFlight
+	if inFlight == 0 {
+		inFlight = 10
+	}
+	var e encoder
+	e.varuint32(reqGetBlocks)
+	e.u32(uint32(start))
+	e.u32(uint32(end))
+	e.u32(inFlight)
+	e.varuint32(0)      // have_positions
+	e.boolean(true)     // irreversible_only
+	e.boolean(true)     // fetch_block，只用来取 block 时间
+	e.boolean(true)     // fetch_traces
+	e.boolean(c.Deltas) // fetch_deltas
+	return e.buf
+}
+
+func ack() []byte {
+	var e encoder
+	e.varuint32(reqAckBlocks)
+	e.u32(1)
+	return e.buf
+}
+
+// Run : 连接并一直处理到 stop 关闭或到达 End，断线后退避重连
+func (c *Client) Run(p *follow.Pipeline, stop <-chan struct{}) error {
+	failures := 0
+	for {
+		next, err := p.Next(c.Start)
+		if nil != err {
+			return err
+		}
+		if c.End > 0 && next >= c.End {
+			log.Infof("ship.Run - %s reached end block %d", p.Name, c.End)
+			return nil
+		}
+		progressed, err := c.session(p, next, stop)
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		if nil == err {
+			return nil
+		}
+		if progressed {
+			failures = 0
+		}
+		log.Warnf("ship.Run - session from %d failed : %v", next, err)
+		select {
+		case <-time.After(budget.Backoff(failures)):
+		case <-stop:
+			return nil
+		}
+		failures++
+	}
+}
+
+// session : 一次连接，返回是否处理过 block
+func (c *Client) session(p *follow.Pipeline, next uint64, stop <-chan struct{}) (progressed bool, err error) {
+	conn, _, err := websocket.DefaultDialer.Dial(c.URL, nil)
+	if nil != err {
+		return false, err
+	}
+	var closeOnce sync.Once
+	closeConn := func() { closeOnce.Do(func() { conn.Close() }) }
+	defer closeConn()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			closeConn()
+		case <-done:
+		}
+	}()
+
+	// 第一帧是 ABI，这里的类型是写死的，不需要
+	typ, frame, err := conn.ReadMessage()
+	if nil != err {
+		return false, err
+	}
+	if err = c.record(typ, frame); nil != err {
+		return false, err
+	}
+	if err = conn.WriteMessage(websocket.BinaryMessage, c.request(next)); nil != err {
+		return false, err
+	}
+	log.Infof("ship.session - %s from block %d", p.Name, next)
+
+	for {
+		typ, frame, err = conn.ReadMessage()
+		if nil != err {
+			return progressed, err
+		}
+		if err = c.record(typ, frame); nil != err {
+			return progressed, err
+		}
+		var res *blocksResult
+		if res, err = decodeResult(frame); nil != err {
+			return progressed, fmt.Errorf("decode result : %v", err)
+		}
+		if res != nil && res.ThisBlock != nil {
+			num := uint64(res.ThisBlock.BlockNum)
+			if num != next {
+				return progressed, fmt.Errorf("expect block %d, got %d", next, num)
+			}
+			block := &eosapi.Block{Num: num, ID: res.ThisBlock.BlockID, Timestamp: blockTime(res.Block)}
+			if block.Actions, err = decodeTraces(res.Traces, num, block.Timestamp); nil != err {
+				return progressed, fmt.Errorf("decode traces of block %d : %v", num, err)
+			}
+			var deltas []*follow.TableDelta
+			if deltas, err = decodeDeltas(res.Deltas, num); nil != err {
+				return progressed, fmt.Errorf("decode deltas of block %d : %v", num, err)
+			}
+			if err = p.Apply(block, deltas); nil != err {
+				return progressed, fmt.Errorf("apply block %d : %v", num, err)
+			}
+			progressed = true
+			metrics.SetLag(p.Name, num, uint64(res.Head.BlockNum), uint64(res.LastIrreversible.BlockNum))
+			next++
+			if num%1000 == 0 {
+				log.Infof("ship.session - %s at block %d, irreversible %d", p.Name, num, res.LastIrreversible.BlockNum)
+			}
+		}
+		if err = conn.WriteMessage(websocket.BinaryMessage, ack()); nil != err {
+			return progressed, err
+		}
+		if c.End > 0 && next >= c.End {
+			return progressed, nil
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package ship
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+)
+
+// testdata/blocks.bin 是按 Client.Record 的格式录下的一段回放：ABI 帧，block 100 和空的 block 101。
+// block 100 包含：
+//   - 已执行的交易：eosio::vote(action_trace_v0)，eosio.token::transfer(action_trace_v1，带 return_value)，
+//     以及发给 alice 的 transfer 通知，还带 account_ram_delta 和 partial_transaction
+//   - 失败的交易：其中的 action 不应返回
+//   - 表变化：table_delta_v0 的 contract_row，table_delta_v1 的 contract_row 删除，以及要跳过的 account 表
+//
+// 修改下面的 fixture 之后用 go test -run TestFixture -update 重新生成。
+var update = flag.Bool("update", false, "重新生成 testdata/blocks.bin")
+
+const fixturePath = "testdata/blocks.bin"
+
+func (e *encoder) u16(v uint16) {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) u64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) bytes(v []byte) {
+	e.varuint32(uint32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) str(v string) {
+	e.bytes([]byte(v))
+}
+
+func (e *encoder) name(v string) {
+	e.u64(eosapi.StrToName(v))
+}
+
+// checksum256 : 32个字节都是 b
+func (e *encoder) checksum256(b byte) {
+	e.buf = append(e.buf, bytes.Repeat([]byte{b}, 32)...)
+}
+
+func (e *encoder) asset(amount int64, precision uint8, code string) {
+	e.u64(uint64(amount))
+	sym := uint64(precision)
+	for idx := 0; idx < len(code); idx++ {
+		sym |= uint64(code[idx]) << (8 * uint(idx+1))
+	}
+	e.u64(sym)
+}
+
+// actionTrace : action_trace_v0/v1，data 是 action 的二进制内容
+func (e *encoder) actionTrace(typ uint32, globalSeq uint64, receiver, account, name string, data []byte) {
+	e.varuint32(typ)
+	e.varuint32(1)      // action_ordinal
+	e.varuint32(0)      // creator_action_ordinal
+	e.boolean(true)     // receipt
+	e.varuint32(0)      // action_receipt_v0
+	e.name(receiver)    // receiver
+	e.checksum256(0xad) // act_digest
+	e.u64(globalSeq)
+	e.u64(globalSeq - 1000) // recv_sequence
+	e.varuint32(1)
+	e.name(account)
+	e.u64(7)
+	e.varuint32(1) // code_sequence
+	e.varuint32(1) // abi_sequence
+	e.name(receiver)
+	e.name(account)
+	e.name(name)
+	e.varuint32(1)
+	e.name("alice")
+	e.name("active")
+	e.bytes(data)
+	e.boolean(false) // context_free
+	e.u64(25)        // elapsed
+	e.str("")        // console
+	e.varuint32(0)   // account_ram_deltas
+	e.boolean(false) // except
+	e.boolean(false) // error_code
+	if typ == 1 {
+		e.bytes([]byte{0x01}) // return_value
+	}
+}
+
+// trxHead : transaction_trace_v0 在 action_traces 之前的部分
+func (e *encoder) trxHead(id byte, status uint8) {
+	e.varuint32(0)
+	e.checksum256(id)
+	e.u8(status)
+	e.u32(120)      // cpu_usage_us
+	e.varuint32(16) // net_usage_words
+	e.u64(300)      // elapsed
+	e.u64(128)      // net_usage
+	e.boolean(false)
+}
+
+func voteData() []byte {
+	var e encoder
+	e.name("alice")
+	e.name("bpa")
+	e.asset(100000, 4, "EOS")
+	return e.buf
+}
+
+func transferData(from, to string) []byte {
+	var e encoder
+	e.name(from)
+	e.name(to)
+	e.asset(12345, 4, "EOS")
+	e.str("hello")
+	return e.buf
+}
+
+func traces() []byte {
+	var e encoder
+	e.varuint32(2)
+
+	e.trxHead(0x11, 0)
+	e.varuint32(3)
+	e.actionTrace(0, 1001, "eosio", "eosio", "vote", voteData())
+	e.actionTrace(1, 1002, "eosio.token", "eosio.token", "transfer", transferData("alice", "bob"))
+	e.actionTrace(0, 1003, "bob", "eosio.token", "transfer", transferData("alice", "bob"))
+	ramDelta := int64(-12)
+	e.boolean(true) // account_ram_delta
+	e.name("alice")
+	e.u64(uint64(ramDelta))
+	e.boolean(false) // except
+	e.boolean(false) // error_code
+	e.boolean(false) // failed_dtrx_trace
+	e.boolean(true)  // partial
+	e.varuint32(0)
+	e.u32(1528617600) // expiration
+	e.u16(99)         // ref_block_num
+	e.u32(0xdeadbeef) // ref_block_prefix
+	e.varuint32(0)    // max_net_usage_words
+	e.u8(0)           // max_cpu_usage_ms
+	e.varuint32(0)    // delay_sec
+	e.varuint32(0)    // transaction_extensions
+	e.varuint32(1)    // signatures
+	e.varuint32(0)
+	e.buf = append(e.buf, bytes.Repeat([]byte{0x5a}, 65)...)
+	e.varuint32(0) // context_free_data
+
+	e.trxHead(0x22, 3) // hard_fail
+	e.varuint32(1)
+	e.actionTrace(0, 1004, "eosio", "eosio", "vote", voteData())
+	e.boolean(false)
+	e.boolean(true) // except
+	e.str("assertion failure")
+	e.boolean(true) // error_code
+	e.u64(1)
+	e.boolean(false)
+	e.boolean(false)
+	return e.buf
+}
+
+func contractRow(pk uint64, value []byte) []byte {
+	var e encoder
+	e.varuint32(0)
+	e.name("eosio")
+	e.name("eosio")
+	e.name("voters")
+	e.u64(pk)
+	e.name("alice")
+	e.bytes(value)
+	return e.buf
+}
+
+func deltas() []byte {
+	var e encoder
+	e.varuint32(3)
+	e.varuint32(0)
+	e.str("contract_row")
+	e.varuint32(1)
+	e.boolean(true)
+	e.bytes(contractRow(42, []byte{1, 2, 3}))
+
+	e.varuint32(1)
+	e.str("contract_row")
+	e.varuint32(1)
+	e.boolean(false)
+	e.bytes(contractRow(43, nil))
+
+	e.varuint32(0)
+	e.str("account")
+	e.varuint32(1)
+	e.boolean(true)
+	e.bytes([]byte{9, 9, 9})
+	return e.buf
+}
+
+// result : get_blocks_result_v0，空的 block 不带 block、traces 和 deltas
+func result(num uint32, full bool) []byte {
+	var e encoder
+	e.varuint32(resGetBlocks)
+	e.u32(200)
+	e.checksum256(0xc8)
+	e.u32(150)
+	e.checksum256(0x96)
+	e.boolean(true)
+	e.u32(num)
+	e.checksum256(byte(num))
+	e.boolean(true)
+	e.u32(num - 1)
+	e.checksum256(byte(num - 1))
+	if !full {
+		e.boolean(false)
+		e.boolean(false)
+		e.boolean(false)
+		return e.buf
+	}
+	var block encoder
+	block.u32(1163865600) // 2018-06-10T08:00:00
+	block.name("bpa")
+	e.boolean(true)
+	e.bytes(block.buf)
+	e.boolean(true)
+	e.bytes(traces())
+	e.boolean(true)
+	e.bytes(deltas())
+	return e.buf
+}
+
+func fixture() []byte {
+	var buf bytes.Buffer
+	writeFrame(&buf, websocket.TextMessage, []byte(`{"version":"eosio::abi/1.1"}`))
+	writeFrame(&buf, websocket.BinaryMessage, result(100, true))
+	writeFrame(&buf, websocket.BinaryMessage, result(101, false))
+	return buf.Bytes()
+}
+
+func TestFixture(t *testing.T) {
+	if *update {
+		if err := ioutil.WriteFile(fixturePath, fixture(), 0644); nil != err {
+			t.Fatal(err)
+		}
+	}
+	recorded, err := ioutil.ReadFile(fixturePath)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recorded, fixture()) {
+		t.Fatalf("%s is out of date, run go test -run TestFixture -update", fixturePath)
+	}
+}
+
+// replayed : 连上 ReplayHandler，发出请求后收下全部结果帧
+func replayed(t *testing.T) [][]byte {
+	srv := httptest.NewServer(ReplayHandler(fixturePath))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	typ, abi, err := conn.ReadMessage()
+	if nil != err || typ != websocket.TextMessage || !strings.Contains(string(abi), "eosio::abi") {
+		t.Fatalf("abi frame : %d %q %v", typ, abi, err)
+	}
+	if err = conn.WriteMessage(websocket.BinaryMessage, (&Client{}).request(100)); nil != err {
+		t.Fatal(err)
+	}
+	var frames [][]byte
+	for {
+		_, frame, err := conn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			return frames
+		}
+		if nil != err {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+		conn.WriteMessage(websocket.BinaryMessage, ack())
+	}
+}
+
+func TestReplayDecode(t *testing.T) {
+	frames := replayed(t)
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+
+	res, err := decodeResult(frames[0])
+	if nil != err {
+		t.Fatal(err)
+	}
+	if res.ThisBlock == nil || res.ThisBlock.BlockNum != 100 || res.Head.BlockNum != 200 || res.LastIrreversible.BlockNum != 150 {
+		t.Fatalf("positions : %+v", res)
+	}
+	timestamp := blockTime(res.Block)
+	if timestamp != "2018-06-10T08:00:00.000" {
+		t.Errorf("block time %s", timestamp)
+	}
+
+	actions, err := decodeTraces(res.Traces, 100, timestamp)
+	if nil != err {
+		t.Fatal(err)
+	}
+	wantActions := []struct {
+		seq     uint64
+		account string
+		name    string
+		data    string
+	}{
+		{1001, "eosio", "vote", `{"voter":"alice","bpname":"bpa","stake":"10.0000 EOS"}`},
+		{1002, "eosio.token", "transfer", `{"from":"alice","to":"bob","quantity":"1.2345 EOS","memo":"hello"}`},
+	}
+	if len(actions) != len(wantActions) {
+		t.Fatalf("got %d actions, want %d : %+v", len(actions), len(wantActions), actions)
+	}
+	for idx, want := range wantActions {
+		act := actions[idx]
+		a := act.ActionTrace.Act
+		if act.GlobalActionSeq != want.seq || a.Account != want.account || a.Name != want.name || string(a.Data) != want.data {
+			t.Errorf("action %d : seq %d %s::%s %s, want %+v", idx, act.GlobalActionSeq, a.Account, a.Name, a.Data, want)
+		}
+		if act.BlockNum != 100 || act.BlockTime != timestamp || act.ActionTrace.TrxID != strings.Repeat("11", 32) {
+			t.Errorf("action %d : block %d @ %s trx %s", idx, act.BlockNum, act.BlockTime, act.ActionTrace.TrxID)
+		}
+		if len(a.Authorization) != 1 || a.Authorization[0].Actor != "alice" {
+			t.Errorf("action %d : authorization %+v", idx, a.Authorization)
+		}
+	}
+
+	rows, err := decodeDeltas(res.Deltas, 100)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d deltas, want 2", len(rows))
+	}
+	if r := rows[0]; !r.Present || r.Code != "eosio" || r.Table != "voters" || r.PrimaryKey != 42 || r.Payer != "alice" || !bytes.Equal(r.Value, []byte{1, 2, 3}) {
+		t.Errorf("delta 0 : %+v", r)
+	}
+	if r := rows[1]; r.Present || r.PrimaryKey != 43 || r.BlockNum != 100 {
+		t.Errorf("delta 1 : %+v", r)
+	}
+
+	res, err = decodeResult(frames[1])
+	if nil != err {
+		t.Fatal(err)
+	}
+	if res.ThisBlock == nil || res.ThisBlock.BlockNum != 101 || res.Block != nil || res.Traces != nil || res.Deltas != nil {
+		t.Fatalf("empty block : %+v", res)
+	}
+	if actions, err = decodeTraces(res.Traces, 101, blockTime(res.Block)); nil != err || len(actions) != 0 {
+		t.Errorf("empty block traces : %v %v", actions, err)
+	}
+}
+
+func TestDecodeActionData(t *testing.T) {
+	var voterBP encoder
+	voterBP.name("alice")
+	voterBP.name("bpa")
+	cases := []struct {
+		account string
+		name    string
+		data    []byte
+		want    string
+	}{
+		{"eosio", "vote", voteData(), `{"voter":"alice","bpname":"bpa","stake":"10.0000 EOS"}`},
+		{"eosio", "unfreeze", voterBP.buf, `{"voter":"alice","bpname":"bpa"}`},
+		{"eosio", "claim", voterBP.buf, `{"voter":"alice","bpname":"bpa"}`},
+		{"eosio.token", "transfer", transferData("eosio", "alice"), `{"from":"eosio","to":"alice","quantity":"1.2345 EOS","memo":"hello"}`},
+		{"other", "claim", voterBP.buf, ""},         // 只认 eosio 合约的
+		{"eosio", "claim", voteData(), ""},          // 多出来的字节
+		{"eosio", "unfreeze", voterBP.buf[:12], ""}, // 不够长
+		{"eosio", "updatebp", voterBP.buf, ""},      // 不认识的 action
+	}
+	for _, c := range cases {
+		if got := string(decodeActionData(c.account, c.name, c.data)); got != c.want {
+			t.Errorf("%s::%s = %s, want %s", c.account, c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package ship
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/follow"
+)
+
+// 请求和结果的 variant 序号，见 state_history_plugin 的 ABI
+const (
+	reqGetStatus  = 0
+	reqGetBlocks  = 1
+	reqAckBlocks  = 2
+	resGetStatus  = 0
+	resGetBlocks  = 1
+	blockEpochSec = 946684800 // block_timestamp 从 2000-01-01 起，每个 slot 0.5 秒
+)
+
+// blockPosition : block_position
+type blockPosition struct {
+	BlockNum uint32
+	BlockID  string
+}
+
+// blocksResult : get_blocks_result_v0
+type blocksResult struct {
+	Head             blockPosition
+	LastIrreversible blockPosition
+	ThisBlock        *blockPosition
+	Block            []byte
+	Traces           []byte
+	Deltas           []byte
+}
+
+func (d *decoder) blockPosition() blockPosition {
+	return blockPosition{BlockNum: d.u32(), BlockID: d.checksum256()}
+}
+
+func (d *decoder) optionalBytes() []byte {
+	if !d.optional() {
+		return nil
+	}
+	return d.bytes()
+}
+
+// decodeResult : 解析一帧结果，不是 get_blocks_result_v0 的返回 nil
+func decodeResult(frame []byte) (*blocksResult, error) {
+	d := newDecoder(frame)
+	if typ := d.varuint32(); typ != resGetBlocks {
+		return nil, d.err
+	}
+	var res blocksResult
+	res.Head = d.blockPosition()
+	res.LastIrreversible = d.blockPosition()
+	if d.optional() {
+		pos := d.blockPosition()
+		res.ThisBlock = &pos
+	}
+	if d.optional() {
+		d.blockPosition() // prev_block
+	}
+	res.Block = d.optionalBytes()
+	res.Traces = d.optionalBytes()
+	res.Deltas = d.optionalBytes()
+	return &res, d.err
+}
+
+// blockTime : signed_block 的第一个字段就是 block_timestamp，只解这一个
+func blockTime(block []byte) string {
+	if len(block) < 4 {
+		return ""
+	}
+	slot := newDecoder(block).u32()
+	tm := time.Unix(blockEpochSec, 0).UTC().Add(time.Duration(slot) * 500 * time.Millisecond)
+	return tm.Format(eosapi.TimeLayout + ".000")
+}
+
+// decodeTraces : 解析 vector<transaction_trace>，返回已执行交易中合约自己收到的 action。没有 traces 时返回空
+func decodeTraces(traces []byte, num uint64, timestamp string) ([]eosapi.Action, error) {
+	if len(traces) == 0 {
+		return nil, nil
+	}
+	d := newDecoder(traces)
+	var actions []eosapi.Action
+	for n := d.varuint32(); n > 0 && d.err == nil; n-- {
+		actions = d.transactionTrace(actions, num, timestamp, true)
+	}
+	return actions, d.err
+}
+
+// transactionTrace : transaction_trace_v0，keep 为 false 时只跳过不收集
+func (d *decoder) transactionTrace(actions []eosapi.Action, num uint64, timestamp string, keep bool) []eosapi.Action {
+	if typ := d.varuint32(); typ != 0 && d.err == nil {
+		d.err = fmt.Errorf("unsupported transaction_trace variant %d", typ)
+		return actions
+	}
+	trxID := d.checksum256()
+	status := d.u8()
+	d.u32()       // cpu_usage_us
+	d.varuint32() // net_usage_words
+	d.i64()       // elapsed
+	d.u64()       // net_usage
+	d.boolean()   // scheduled
+
+	keep = keep && status == 0 // 0 为 executed
+	for n := d.varuint32(); n > 0 && d.err == nil; n-- {
+		if act := d.actionTrace(num, timestamp, trxID); act != nil && keep {
+			actions = append(actions, *act)
+		}
+	}
+	if d.optional() { // account_ram_delta
+		d.name()
+		d.i64()
+	}
+	if d.optional() { // except
+		d.str()
+	}
+	if d.optional() { // error_code
+		d.u64()
+	}
+	if d.optional() { // failed_dtrx_trace
+		d.transactionTrace(nil, num, timestamp, false)
+	}
+	if d.optional() {
+		d.partialTransaction()
+	}
+	return actions
+}
+
+// actionTrace : action_trace_v0/v1，只返回有 receipt 且 receiver 是合约自己的
+func (d *decoder) actionTrace(num uint64, timestamp, trxID string) *eosapi.Action {
+	typ := d.varuint32()
+	if typ > 1 && d.err == nil {
+		d.err = fmt.Errorf("unsupported action_trace variant %d", typ)
+		return nil
+	}
+	d.varuint32() // action_ordinal
+	d.varuint32() // creator_action_ordinal
+	var globalSeq uint64
+	hasReceipt := d.optional()
+	if hasReceipt {
+		d.varuint32() // action_receipt_v0
+		d.name()      // receiver
+		d.checksum256()
+		globalSeq = d.u64()
+		d.u64() // recv_sequence
+		for n := d.varuint32(); n > 0 && d.err == nil; n-- {
+			d.name()
+			d.u64()
+		}
+		d.varuint32() // code_sequence
+		d.varuint32() // abi_sequence
+	}
+	receiver := d.name()
+
+	var act eosapi.Action
+	act.GlobalActionSeq = globalSeq
+	act.BlockNum = num
+	act.BlockTime = timestamp
+	act.ActionTrace.TrxID = trxID
+	act.ActionTrace.Act.Account = d.name()
+	act.ActionTrace.Act.Name = d.name()
+	for n := d.varuint32(); n > 0 && d.err == nil; n-- {
+		act.ActionTrace.Act.Authorization = append(act.ActionTrace.Act.Authorization,
+			eosapi.Authorization{Actor: d.name(), Permission: d.name()})
+	}
+	data := d.bytes()
+
+	d.boolean() // context_free
+	d.i64()     // elapsed
+	d.str()     // console
+	for n := d.varuint32(); n > 0 && d.err == nil; n-- {
+		d.name()
+		d.i64()
+	}
+	if d.optional() { // except
+		d.str()
+	}
+	if d.optional() { // error_code
+		d.u64()
+	}
+	if typ == 1 {
+		d.bytes() // return_value
+	}
+	if d.err != nil || !hasReceipt || receiver != act.ActionTrace.Act.Account {
+		return nil
+	}
+	act.ActionTrace.Act.HexData = hex.EncodeToString(data)
+	act.ActionTrace.Act.Data = decodeActionData(act.ActionTrace.Act.Account, act.ActionTrace.Act.Name, data)
+	return &act
+}
+
+// partialTransaction : partial_transaction_v0，全部跳过
+func (d *decoder) partialTransaction() {
+	if typ := d.varuint32(); typ != 0 && d.err == nil {
+		d.err = fmt.Errorf("unsupported partial_transaction variant %d", typ)
+		return
+	}
+	d.u32()       // expiration
+	d.u16()       // ref_block_num
+	d.u32()       // ref_block_prefix
+	d.varuint32() // max_net_usage_words
+	d.u8()        // max_cpu_usage_ms
+	d.varuint32() // delay_sec
+	for n := d.varuint32(); n > 0 && d.err == nil; n-- {
+		d.u16()
+		d.bytes()
+	}
+	for n := d.varuint32(); n > 0 && d.err == nil; n-- {
+		d.signature()
+	}
+	for n := d.varuint32(); n > 0 && d.err == nil; n-- {
+		d.bytes()
+	}
+}
+
+// decodeDeltas : 解析 vector<table_delta>，只取 contract_row。没有请求表变化时 deltas 为空
+func decodeDeltas(deltas []byte, num uint64) ([]*follow.TableDelta, error) {
+	if len(deltas) == 0 {
+		return nil, nil
+	}
+	d := newDecoder(deltas)
+	var result []*follow.TableDelta
+	for n := d.varuint32(); n > 0 && d.err == nil; n-- {
+		if typ := d.varuint32(); typ > 1 && d.err == nil {
+			return result, fmt.Errorf("unsupported table_delta variant %d", typ)
+		}
+		name := d.str()
+		for rows := d.varuint32(); rows > 0 && d.err == nil; rows-- {
+			present := d.boolean()
+			data := d.bytes()
+			if name != "contract_row" || d.err != nil {
+				continue
+			}
+			row := newDecoder(data)
+			row.varuint32() // contract_row_v0
+			delta := &follow.TableDelta{
+				BlockNum:   num,
+				Present:    present,
+				Code:       row.name(),
+				Scope:      row.name(),
+				Table:      row.name(),
+				PrimaryKey: row.u64(),
+				Payer:      row.name(),
+				Value:      row.bytes(),
+			}
+			if row.err != nil {
+				return result, fmt.Errorf("contract_row : %v", row.err)
+			}
+			result = append(result, delta)
+		}
+	}
+	return result, d.err
+}
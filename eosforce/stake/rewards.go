@@ -0,0 +1,183 @@
+package stake
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// 汇总分红的时间段
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// PeriodStart : tm 所在时间段的起点（UTC），周从周一开始
+func PeriodStart(tm time.Time, interval string) (time.Time, error) {
+	tm = tm.UTC()
+	day := time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case IntervalDay:
+		return day, nil
+	case IntervalWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)), nil
+	case IntervalMonth:
+		return time.Date(tm.Year(), tm.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	return time.Time{}, fmt.Errorf("interval '%s' invalid, should be %s, %s or %s", interval, IntervalDay, IntervalWeek, IntervalMonth)
+}
+
+// Period : 一个时间段内一个投票人从一个 BP 领到的分红
+type Period struct {
+	Start  time.Time
+	BPName string
+	Voter  string // perVoter 为 false 时为空，表示 BP 的合计
+	Claims int
+	Reward uint64
+}
+
+// Periods : 把 events 中的 claim 按时间段汇总，按 Start、BPName、Voter 排序
+func Periods(events []*Event, interval string, perVoter bool) ([]*Period, error) {
+	type key struct {
+		start     time.Time
+		bp, voter string
+	}
+	sums := make(map[key]*Period)
+	for _, ev := range events {
+		if ev.Action != ActionClaim {
+			continue
+		}
+		start, err := PeriodStart(ev.BlockTime, interval)
+		if nil != err {
+			return nil, err
+		}
+		k := key{start, ev.BPName, ""}
+		if perVoter {
+			k.voter = ev.Voter
+		}
+		p, ok := sums[k]
+		if !ok {
+			p = &Period{Start: start, BPName: ev.BPName, Voter: k.voter}
+			sums[k] = p
+		}
+		p.Claims++
+		p.Reward += ev.Reward
+	}
+	periods := make([]*Period, 0, len(sums))
+	for _, p := range sums {
+		periods = append(periods, p)
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		a, b := periods[i], periods[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		if a.BPName != b.BPName {
+			return a.BPName < b.BPName
+		}
+		return a.Voter < b.Voter
+	})
+	return periods, nil
+}
+
+// Share : 一个投票人在一段时间内实际领到的分红份额和按票龄应得的份额
+type Share struct {
+	Voter    string
+	Claims   int
+	Reward   uint64  // 这段时间内领到的分红，最小单位
+	VoteAge  float64 // 这段时间内的票龄，抵押（最小单位）乘以 block 数
+	Actual   float64 // Reward 占全部投票人领到的分红的比例
+	Expected float64 // VoteAge 占全部投票人票龄的比例
+}
+
+// Anomalous : 实际份额偏离应得份额超过 tolerance 倍（如 0.5 为偏离50%）。
+// 这段时间没有领过的不算，分红累积到领取时才发放，时间段太短时偏差会很大
+func (s *Share) Anomalous(tolerance float64) bool {
+	if s.Claims == 0 {
+		return false
+	}
+	if s.Expected == 0 {
+		return s.Reward > 0
+	}
+	return math.Abs(s.Actual-s.Expected) > tolerance*s.Expected
+}
+
+// Shares : 按一个 BP 的全部记录（按 SeqNum 排序）计算 [begin, end] 内每个投票人的分红份额和票龄份额，
+// 按应得份额从大到小排序。票龄按 block 时间和 BlockInterval 折算成 block 数。
+// 这段时间内有 claim 没有记录到分红(Reward 为0)的投票人领到多少不知道，不参与计算，
+// 他们的票龄和分红都不计入合计，按名字排序后以 unknown 返回
+func Shares(events []*Event, begin, end time.Time) (shares []*Share, unknown []string) {
+	type voterAge struct {
+		share   *Share
+		staked  uint64
+		since   time.Time
+		unknown bool
+	}
+	// overlap : [from, to) 和 [begin, end] 重叠的 block 数
+	overlap := func(from, to time.Time) float64 {
+		if from.Before(begin) {
+			from = begin
+		}
+		if to.After(end) {
+			to = end
+		}
+		if !to.After(from) {
+			return 0
+		}
+		return float64(to.Sub(from)) / float64(BlockInterval)
+	}
+
+	ages := make(map[string]*voterAge)
+	for _, ev := range events {
+		va, ok := ages[ev.Voter]
+		if !ok {
+			va = &voterAge{share: &Share{Voter: ev.Voter}, since: ev.BlockTime}
+			ages[ev.Voter] = va
+		}
+		switch ev.Action {
+		case ActionVote:
+			va.share.VoteAge += float64(va.staked) * overlap(va.since, ev.BlockTime)
+			va.staked, va.since = ev.Stake, ev.BlockTime
+		case ActionClaim:
+			if !ev.BlockTime.Before(begin) && !ev.BlockTime.After(end) {
+				va.share.Claims++
+				va.share.Reward += ev.Reward
+				va.unknown = va.unknown || ev.Reward == 0
+			}
+		}
+	}
+
+	var totalAge float64
+	var totalReward uint64
+	shares = make([]*Share, 0, len(ages))
+	for _, va := range ages {
+		if va.unknown {
+			unknown = append(unknown, va.share.Voter)
+			continue
+		}
+		va.share.VoteAge += float64(va.staked) * overlap(va.since, end)
+		totalAge += va.share.VoteAge
+		totalReward += va.share.Reward
+		if va.share.VoteAge > 0 || va.share.Claims > 0 {
+			shares = append(shares, va.share)
+		}
+	}
+	for _, s := range shares {
+		if totalAge > 0 {
+			s.Expected = s.VoteAge / totalAge
+		}
+		if totalReward > 0 {
+			s.Actual = float64(s.Reward) / float64(totalReward)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Expected != shares[j].Expected {
+			return shares[i].Expected > shares[j].Expected
+		}
+		return shares[i].Voter < shares[j].Voter
+	})
+	sort.Strings(unknown)
+	return shares, unknown
+}
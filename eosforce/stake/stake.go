@@ -0,0 +1,271 @@
+// Package stake 记录 EOSForce 投票人的抵押变化，重建每个投票人对每个 BP 的抵押、解冻中和可取回的额度。
+//
+// EOSForce 的 vote 把投票人对一个 BP 的抵押改为 stake，减少的部分进入解冻，
+// FreezeBlocks 个 block 之后才能用 unfreeze 取回，解冻期间再减少时累加并重新计时；
+// claim 领取投票分红，分红金额由合约计算，不在 action 的 data 中，取自它发出的、转给投票人的 inline transfer。
+//
+// StakeEvent 表只保存 action 本身，额度在读取时按 SeqNum 顺序重放得到，所以回溯的方向和先后不影响结果，
+// 但某个投票人对某个 BP 最早的记录之前的抵押按0计算。
+package stake
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/metrics"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+var log = logging.New("stake")
+
+// 记录的 action
+const (
+	ActionVote     = "vote"
+	ActionUnfreeze = "unfreeze"
+	ActionClaim    = "claim"
+)
+
+// FreezeBlocks : 减少的抵押要等多少个 block 才能取回，EOSForce 为3天
+const FreezeBlocks = 3 * 24 * 60 * 20
+
+// SystemAccount : 发放投票分红的系统账号，claim 的分红只认它转出的
+const SystemAccount = "eosio"
+
+// BlockInterval : 出块间隔，用来估算解冻到期的时间
+const BlockInterval = 3 * time.Second
+
+// Event : StakeEvent 表中的一行
+type Event struct {
+	SeqNum    uint64
+	BlockNum  uint64
+	BlockTime time.Time
+	Voter     string
+	BPName    string
+	Action    string // 见 Action* 常量
+	Stake     uint64 // vote 之后的抵押额，核心币的最小单位，其它 action 为0
+	Reward    uint64 // claim 领到的分红，最小单位，没有找到 inline transfer 时为0
+}
+
+// AddTables : 在dbmap上注册 StakeEvent 表
+func AddTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(Event{}, "StakeEvent").SetKeys(false, "SeqNum")
+}
+
+// actionData : vote/unfreeze/claim 的 data，后两者没有 stake
+type actionData struct {
+	Voter  string `json:"voter"`
+	BPName string `json:"bpname"`
+	Stake  string `json:"stake"`
+}
+
+// ParseEvent : 解析 eosio 合约的 vote/unfreeze/claim，其它 action 返回 nil。
+// token 是核心币合约，claim 的分红取自它的 inline transfer
+func ParseEvent(act *eosapi.Action, token, symbol string, precision int) (*Event, error) {
+	a := &act.ActionTrace.Act
+	if a.Account != "eosio" || (a.Name != ActionVote && a.Name != ActionUnfreeze && a.Name != ActionClaim) {
+		return nil, nil
+	}
+	var data actionData
+	if err := json.Unmarshal(a.Data, &data); nil != err {
+		return nil, err
+	}
+	if data.Voter == "" || data.BPName == "" {
+		return nil, fmt.Errorf("%s of seq %d has no voter or bpname", a.Name, act.GlobalActionSeq)
+	}
+	ev := &Event{
+		SeqNum:    act.GlobalActionSeq,
+		BlockNum:  act.BlockNum,
+		BlockTime: act.Time(),
+		Voter:     data.Voter,
+		BPName:    data.BPName,
+		Action:    a.Name,
+	}
+	if a.Name == ActionClaim {
+		ev.Reward = rewardOf(act.ActionTrace.InlineTransfers(token), data.Voter, symbol, precision)
+	}
+	if a.Name == ActionVote {
+		amount, sym, err := eosapi.ParseAsset(data.Stake, precision)
+		if nil != err {
+			return nil, err
+		}
+		if sym != symbol {
+			return nil, fmt.Errorf("stake '%s' is not '%s'", data.Stake, symbol)
+		}
+		ev.Stake = amount
+	}
+	return ev, nil
+}
+
+// rewardOf : transfers 中系统账号转给 voter 的核心币合计，transfers 必须已经限定为核心币合约的
+func rewardOf(transfers []*eosapi.TransferData, voter, symbol string, precision int) uint64 {
+	var reward uint64
+	for _, t := range transfers {
+		if t.From != SystemAccount || t.To != voter {
+			continue
+		}
+		amount, sym, err := eosapi.ParseAsset(t.Quantity, precision)
+		if nil != err || sym != symbol {
+			continue
+		}
+		reward += amount
+	}
+	return reward
+}
+
+var saveEventSQL = store.UpsertSQL("StakeEvent", []string{"SeqNum"}, "SeqNum", "BlockNum", "BlockTime", "Voter", "BPName", "Action", "Stake", "Reward")
+
+// Save : 保存一条记录，exec 可以是事务
+func Save(exec gorp.SqlExecutor, ev *Event) error {
+	defer metrics.ObserveDB("stake", time.Now())
+	_, err := exec.Exec(saveEventSQL, ev.SeqNum, ev.BlockNum, ev.BlockTime, ev.Voter, ev.BPName, ev.Action, ev.Stake, ev.Reward)
+	return err
+}
+
+// SaveAll : 在一个事务里保存一批记录
+func SaveAll(dbmap *gorp.DbMap, events []*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	trans, err := dbmap.Begin()
+	if nil != err {
+		return err
+	}
+	for _, ev := range events {
+		if err = Save(trans, ev); nil != err {
+			trans.Rollback()
+			return err
+		}
+	}
+	return trans.Commit()
+}
+
+// Events : voter 的全部记录，bp 不为空时只要这个 BP 的，voter 为空时为 BP 的全部记录，按 SeqNum 排序
+func Events(exec gorp.SqlExecutor, voter, bp string) ([]*Event, error) {
+	query := "SELECT * FROM StakeEvent WHERE 1=1"
+	var args []interface{}
+	if voter != "" {
+		query += " AND Voter=?"
+		args = append(args, voter)
+	}
+	if bp != "" {
+		query += " AND BPName=?"
+		args = append(args, bp)
+	}
+	var events []*Event
+	if _, err := exec.Select(&events, query+" ORDER BY SeqNum", args...); nil != err {
+		return nil, err
+	}
+	return events, nil
+}
+
+// State : 一个投票人对一个 BP 在某条记录之后的状态，额度均为最小单位
+type State struct {
+	Event       *Event
+	Staked      uint64    // 正在投票的抵押
+	Unstaking   uint64    // 已从投票中减去、还没有 unfreeze 取回的
+	UnlockBlock uint64    // Unstaking 从这个 block 起可以取回，Unstaking 为0时无意义
+	LastClaim   time.Time // 最后一次领取分红，没有领过为零值
+}
+
+// Unfreezing : 在 blockNum 时还在解冻期的额度
+func (s *State) Unfreezing(blockNum uint64) uint64 {
+	if blockNum < s.UnlockBlock {
+		return s.Unstaking
+	}
+	return 0
+}
+
+// Claimable : 在 blockNum 时已解冻、可以 unfreeze 取回的额度
+func (s *State) Claimable(blockNum uint64) uint64 {
+	if blockNum >= s.UnlockBlock {
+		return s.Unstaking
+	}
+	return 0
+}
+
+// UnlockTime : 按 Event 的 block 时间和出块间隔估算的解冻到期时间
+func (s *State) UnlockTime() time.Time {
+	if s.UnlockBlock <= s.Event.BlockNum {
+		return s.Event.BlockTime
+	}
+	return s.Event.BlockTime.Add(time.Duration(s.UnlockBlock-s.Event.BlockNum) * BlockInterval)
+}
+
+// Replay : 按顺序重放 events，返回每条记录之后该投票人对该 BP 的状态，和 events 一一对应
+func Replay(events []*Event) []*State {
+	type key struct{ voter, bp string }
+	current := make(map[key]State)
+	states := make([]*State, 0, len(events))
+	for _, ev := range events {
+		k := key{ev.Voter, ev.BPName}
+		st := current[k]
+		switch ev.Action {
+		case ActionVote:
+			if ev.Stake < st.Staked {
+				st.Unstaking += st.Staked - ev.Stake
+				st.UnlockBlock = ev.BlockNum + FreezeBlocks
+			}
+			st.Staked = ev.Stake
+		case ActionUnfreeze:
+			if st.Unstaking == 0 {
+				log.Debugf("Replay - %s unfreeze from %s at block %d without known unstaking", ev.Voter, ev.BPName, ev.BlockNum)
+			}
+			st.Unstaking, st.UnlockBlock = 0, 0
+		case ActionClaim:
+			st.LastClaim = ev.BlockTime
+		}
+		st.Event = ev
+		current[k] = st
+		states = append(states, &st)
+	}
+	return states
+}
+
+// Handler : 从 block 流中保存投给 BP 的抵押记录，实现 follow.Handler。
+// block 流中 inline action 紧跟在发出它的 action 之后，claim 之后同一交易中转给投票人的 transfer 计入它的分红
+type Handler struct {
+	BP        string // 为空时保存全部 BP 的记录
+	Token     string // 核心币合约，只有它的 transfer 计入分红
+	Symbol    string
+	Precision int
+
+	claim    *Event // 还在等 inline transfer 的 claim
+	claimTrx string
+}
+
+// Handle : 实现 follow.Handler，解析不了的 action 只打印警告
+func (h *Handler) Handle(exec gorp.SqlExecutor, act *eosapi.Action) error {
+	if h.claim != nil && act.ActionTrace.TrxID != h.claimTrx {
+		h.claim = nil
+	}
+	if a := &act.ActionTrace.Act; h.claim != nil && a.Account == h.Token && a.Name == "transfer" {
+		data := act.Transfer()
+		if data == nil {
+			return nil
+		}
+		reward := rewardOf([]*eosapi.TransferData{data}, h.claim.Voter, h.Symbol, h.Precision)
+		if reward == 0 {
+			return nil
+		}
+		h.claim.Reward += reward
+		_, err := exec.Exec("UPDATE StakeEvent SET Reward=? WHERE SeqNum=?", h.claim.Reward, h.claim.SeqNum)
+		return err
+	}
+
+	ev, err := ParseEvent(act, h.Token, h.Symbol, h.Precision)
+	if nil != err {
+		log.Warnf("stake.Handle - seq %d : %v", act.GlobalActionSeq, err)
+		return nil
+	}
+	if ev == nil || (h.BP != "" && ev.BPName != h.BP) {
+		return nil
+	}
+	if ev.Action == ActionClaim && ev.Reward == 0 {
+		h.claim, h.claimTrx = ev, act.ActionTrace.TrxID
+	}
+	return Save(exec, ev)
+}
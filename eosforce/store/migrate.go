@@ -0,0 +1,234 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gorp/gorp"
+)
+
+// Migration : 一次升级，Version 从 1 开始连续递增，已发布的不能再修改。
+// 两个后端的 SQL 分开写，建出来的表字段和索引要一致
+type Migration struct {
+	Version  int
+	Name     string
+	Sqlite   []string
+	Postgres []string
+}
+
+// statements : backend 要执行的 SQL
+func (m *Migration) statements(backend Backend) []string {
+	if backend.Name() == BackendPostgres {
+		return m.Postgres
+	}
+	return m.Sqlite
+}
+
+// SchemaVersion : schema_version 表中的一行，每成功执行一个 Migration 插入一行
+type SchemaVersion struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// Migrations : 全部升级，按顺序执行。
+// sqlite 的第1个和以前 CreateTablesIfNotExists 建出来的表一致，老库上执行不会改变已有的表。
+var Migrations = []Migration{
+	{1, "create tables", []string{
+		`CREATE TABLE IF NOT EXISTS VoteInfo (SeqNum integer not null primary key, BlockNum integer, Quantity integer,
+			BlockTime datetime, Voter varchar(255), BPName varchar(255), Symbol varchar(255))`,
+		`CREATE TABLE IF NOT EXISTS AccountInfo (Account varchar(255) not null primary key, Amount integer, Notified integer)`,
+		`CREATE TABLE IF NOT EXISTS Payout (ID integer not null primary key autoincrement, Account varchar(255), Amount integer,
+			Memo varchar(255), Status varchar(255), TrxID varchar(255), BlockNum integer, Attempts integer, UpdatedAt datetime)`,
+		`CREATE TABLE IF NOT EXISTS SentTx (TrxID varchar(255) not null primary key, Kind varchar(255), Ref varchar(255),
+			BlockNum integer, Status varchar(255), SentAt datetime, Expiration datetime, CheckedAt datetime)`,
+		`CREATE TABLE IF NOT EXISTS OptOut (Account varchar(255) not null primary key, Memo varchar(255), SeqNum integer,
+			BlockNum integer, BlockTime datetime)`,
+		`CREATE TABLE IF NOT EXISTS OptOutScan (Sender varchar(255) not null primary key, LastSeq integer)`,
+		`CREATE TABLE IF NOT EXISTS FollowCheckpoint (Name varchar(255) not null primary key, BlockNum integer, UpdatedAt datetime)`,
+		`CREATE TABLE IF NOT EXISTS ActionLog (SeqNum integer not null primary key, BlockNum integer, BlockTime datetime,
+			TrxID varchar(255), Account varchar(255), Name varchar(255), Data varchar(255))`,
+		`CREATE TABLE IF NOT EXISTS DeltaLog (ID integer not null primary key autoincrement, BlockNum integer, Present integer,
+			Code varchar(255), Scope varchar(255), TableName varchar(255), PrimaryKey integer, Payer varchar(255), Value varchar(255))`,
+		`CREATE TABLE IF NOT EXISTS WebhookQueue (ID integer not null primary key autoincrement, URL varchar(255), Kind varchar(255),
+			Body varchar(255), Status varchar(255), Attempts integer, NextAt datetime, LastError varchar(255), CreatedAt datetime)`,
+		`CREATE TABLE IF NOT EXISTS ProducerRound (Version integer not null, Round integer not null, Producer varchar(255) not null,
+			Expected integer, Produced integer, UpdatedAt datetime, primary key (Version, Round, Producer))`,
+	}, []string{
+		`CREATE TABLE IF NOT EXISTS VoteInfo (SeqNum bigint not null primary key, BlockNum bigint, Quantity bigint,
+			BlockTime timestamp, Voter text, BPName text, Symbol text)`,
+		`CREATE TABLE IF NOT EXISTS AccountInfo (Account text not null primary key, Amount bigint, Notified boolean)`,
+		`CREATE TABLE IF NOT EXISTS Payout (ID bigserial not null primary key, Account text, Amount bigint,
+			Memo text, Status text, TrxID text, BlockNum bigint, Attempts bigint, UpdatedAt timestamp)`,
+		`CREATE TABLE IF NOT EXISTS SentTx (TrxID text not null primary key, Kind text, Ref text,
+			BlockNum bigint, Status text, SentAt timestamp, Expiration timestamp, CheckedAt timestamp)`,
+		`CREATE TABLE IF NOT EXISTS OptOut (Account text not null primary key, Memo text, SeqNum bigint,
+			BlockNum bigint, BlockTime timestamp)`,
+		`CREATE TABLE IF NOT EXISTS OptOutScan (Sender text not null primary key, LastSeq bigint)`,
+		`CREATE TABLE IF NOT EXISTS FollowCheckpoint (Name text not null primary key, BlockNum bigint, UpdatedAt timestamp)`,
+		`CREATE TABLE IF NOT EXISTS ActionLog (SeqNum bigint not null primary key, BlockNum bigint, BlockTime timestamp,
+			TrxID text, Account text, Name text, Data text)`,
+		`CREATE TABLE IF NOT EXISTS DeltaLog (ID bigserial not null primary key, BlockNum bigint, Present boolean,
+			Code text, Scope text, TableName text, PrimaryKey bigint, Payer text, Value text)`,
+		`CREATE TABLE IF NOT EXISTS WebhookQueue (ID bigserial not null primary key, URL text, Kind text,
+			Body text, Status text, Attempts bigint, NextAt timestamp, LastError text, CreatedAt timestamp)`,
+		`CREATE TABLE IF NOT EXISTS ProducerRound (Version bigint not null, Round bigint not null, Producer text not null,
+			Expected bigint, Produced bigint, UpdatedAt timestamp, primary key (Version, Round, Producer))`,
+	}},
+	{2, "add query indexes", []string{
+		`CREATE INDEX IF NOT EXISTS VoteInfo_Voter ON VoteInfo (Voter, SeqNum)`,
+		`CREATE INDEX IF NOT EXISTS VoteInfo_BPName ON VoteInfo (BPName, BlockTime)`,
+		`CREATE INDEX IF NOT EXISTS VoteInfo_BlockTime ON VoteInfo (BlockTime)`,
+		`CREATE INDEX IF NOT EXISTS AccountInfo_Amount ON AccountInfo (Amount)`,
+		`CREATE INDEX IF NOT EXISTS Payout_Status ON Payout (Status)`,
+		`CREATE INDEX IF NOT EXISTS SentTx_Status ON SentTx (Status)`,
+		`CREATE INDEX IF NOT EXISTS ActionLog_Account ON ActionLog (Account, Name)`,
+		`CREATE INDEX IF NOT EXISTS DeltaLog_Table ON DeltaLog (Code, TableName, Scope, PrimaryKey)`,
+		`CREATE INDEX IF NOT EXISTS WebhookQueue_Status ON WebhookQueue (Status, NextAt)`,
+	}, []string{
+		`CREATE INDEX IF NOT EXISTS VoteInfo_Voter ON VoteInfo (Voter, SeqNum)`,
+		`CREATE INDEX IF NOT EXISTS VoteInfo_BPName ON VoteInfo (BPName, BlockTime)`,
+		`CREATE INDEX IF NOT EXISTS VoteInfo_BlockTime ON VoteInfo (BlockTime)`,
+		`CREATE INDEX IF NOT EXISTS AccountInfo_Amount ON AccountInfo (Amount)`,
+		`CREATE INDEX IF NOT EXISTS Payout_Status ON Payout (Status)`,
+		`CREATE INDEX IF NOT EXISTS SentTx_Status ON SentTx (Status)`,
+		`CREATE INDEX IF NOT EXISTS ActionLog_Account ON ActionLog (Account, Name)`,
+		`CREATE INDEX IF NOT EXISTS DeltaLog_Table ON DeltaLog (Code, TableName, Scope, PrimaryKey)`,
+		`CREATE INDEX IF NOT EXISTS WebhookQueue_Status ON WebhookQueue (Status, NextAt)`,
+	}},
+	{3, "add stake events", []string{
+		`CREATE TABLE IF NOT EXISTS StakeEvent (SeqNum integer not null primary key, BlockNum integer, BlockTime datetime,
+			Voter varchar(255), BPName varchar(255), Action varchar(255), Stake integer)`,
+		`CREATE INDEX IF NOT EXISTS StakeEvent_Voter ON StakeEvent (Voter, BPName, SeqNum)`,
+	}, []string{
+		`CREATE TABLE IF NOT EXISTS StakeEvent (SeqNum bigint not null primary key, BlockNum bigint, BlockTime timestamp,
+			Voter text, BPName text, Action text, Stake bigint)`,
+		`CREATE INDEX IF NOT EXISTS StakeEvent_Voter ON StakeEvent (Voter, BPName, SeqNum)`,
+	}},
+	{4, "add claim rewards", []string{
+		`ALTER TABLE StakeEvent ADD COLUMN Reward integer not null default 0`,
+		`CREATE INDEX IF NOT EXISTS StakeEvent_BPName ON StakeEvent (BPName, Action, BlockTime)`,
+	}, []string{
+		`ALTER TABLE StakeEvent ADD COLUMN Reward bigint not null default 0`,
+		`CREATE INDEX IF NOT EXISTS StakeEvent_BPName ON StakeEvent (BPName, Action, BlockTime)`,
+	}},
+	{5, "add payout rounds", []string{
+		`ALTER TABLE Payout ADD COLUMN Round varchar(255) not null default ''`,
+		`UPDATE Payout SET Round = 'legacy-' || ID WHERE Round = ''`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS Payout_Round ON Payout (Round, Account)`,
+	}, []string{
+		`ALTER TABLE Payout ADD COLUMN Round text not null default ''`,
+		`UPDATE Payout SET Round = 'legacy-' || ID WHERE Round = ''`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS Payout_Round ON Payout (Round, Account)`,
+	}},
+	// 标准 eosio 的代理改票会给每个通过代理投票的人各生成一条记录，它们共用一个 SeqNum
+	{6, "key vote info by seq and voter", []string{
+		`CREATE TABLE VoteInfo_v6 (SeqNum integer not null, BlockNum integer, Quantity integer,
+			BlockTime datetime, Voter varchar(255) not null, BPName varchar(255), Symbol varchar(255), primary key (SeqNum, Voter))`,
+		`INSERT INTO VoteInfo_v6 SELECT SeqNum, BlockNum, Quantity, BlockTime, COALESCE(Voter, ''), BPName, Symbol FROM VoteInfo`,
+		`DROP TABLE VoteInfo`,
+		`ALTER TABLE VoteInfo_v6 RENAME TO VoteInfo`,
+		`CREATE INDEX IF NOT EXISTS VoteInfo_Voter ON VoteInfo (Voter, SeqNum)`,
+		`CREATE INDEX IF NOT EXISTS VoteInfo_BPName ON VoteInfo (BPName, BlockTime)`,
+		`CREATE INDEX IF NOT EXISTS VoteInfo_BlockTime ON VoteInfo (BlockTime)`,
+	}, []string{
+		`UPDATE VoteInfo SET Voter = '' WHERE Voter IS NULL`,
+		`ALTER TABLE VoteInfo DROP CONSTRAINT IF EXISTS voteinfo_pkey`,
+		`ALTER TABLE VoteInfo ADD PRIMARY KEY (SeqNum, Voter)`,
+	}},
+}
+
+// Latest : 当前代码要求的库版本
+func Latest() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// ErrOutdated : 库版本和代码不一致
+type ErrOutdated struct {
+	Path    string
+	Version int
+}
+
+func (e *ErrOutdated) Error() string {
+	if e.Version > Latest() {
+		return fmt.Sprintf("%s is at schema version %d, newer than %d supported by this build", e.Path, e.Version, Latest())
+	}
+	return fmt.Sprintf("%s is at schema version %d, need %d, run 'eosutils migrate' to upgrade it", e.Path, e.Version, Latest())
+}
+
+func addVersionTable(dbmap *gorp.DbMap) error {
+	dbmap.AddTableWithName(SchemaVersion{}, "schema_version").SetKeys(false, "Version")
+	ddl := "CREATE TABLE IF NOT EXISTS schema_version (Version integer not null primary key, Name varchar(255), AppliedAt datetime)"
+	if BackendOf(dbmap).Name() == BackendPostgres {
+		ddl = "CREATE TABLE IF NOT EXISTS schema_version (Version integer not null primary key, Name text, AppliedAt timestamp)"
+	}
+	_, err := dbmap.Exec(ddl)
+	return err
+}
+
+// Version : 库的当前版本，没有执行过任何 Migration 为0
+func Version(dbmap *gorp.DbMap) (int, error) {
+	v, err := dbmap.SelectInt("SELECT COALESCE(MAX(Version),0) FROM schema_version")
+	return int(v), err
+}
+
+// migrate : 从 from 升级到最新，每个 Migration 一个事务
+func migrate(dbmap *gorp.DbMap, backend Backend, from int) error {
+	for _, m := range Migrations {
+		if m.Version <= from {
+			continue
+		}
+		trans, err := dbmap.Begin()
+		if nil != err {
+			return err
+		}
+		for _, stmt := range m.statements(backend) {
+			if _, err = trans.Exec(stmt); nil != err {
+				trans.Rollback()
+				return fmt.Errorf("migration %d '%s' : %v", m.Version, m.Name, err)
+			}
+		}
+		if err = trans.Insert(&SchemaVersion{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}); nil != err {
+			trans.Rollback()
+			return err
+		}
+		if err = trans.Commit(); nil != err {
+			return err
+		}
+		log.Infof("store.migrate - applied %d '%s'", m.Version, m.Name)
+	}
+	return nil
+}
+
+// Migrate : 把 dsn 指定的库升级到最新，backup 为 true 且确实需要升级时先备份，
+// 返回升级前后的版本和备份位置
+func Migrate(dsn string, backup bool) (from, to int, backupPath string, err error) {
+	dbmap, backend, err := open(dsn)
+	if nil != err {
+		return 0, 0, "", err
+	}
+	defer dbmap.Db.Close()
+	if from, err = Version(dbmap); nil != err {
+		return 0, 0, "", err
+	}
+	if from > Latest() {
+		return from, from, "", &ErrOutdated{Path: Redact(dsn), Version: from}
+	}
+	if from == Latest() {
+		return from, from, "", nil
+	}
+	if backup {
+		_, path := Parse(dsn)
+		if backupPath, err = backend.Backup(dbmap, path, from); nil != err {
+			log.Errorf("store.Migrate - backup failed : %v", err)
+			return from, from, "", err
+		}
+		log.Infof("store.Migrate - backed up to %s", backupPath)
+	}
+	if err = migrate(dbmap, backend, from); nil != err {
+		log.Errorf("store.Migrate - %s failed : %v", backend.Name(), err)
+		to, _ = Version(dbmap)
+		return from, to, backupPath, err
+	}
+	return from, Latest(), backupPath, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+// 设置 EOSUTILS_TEST_POSTGRES 为一个可以随意建表的库才运行，如
+// EOSUTILS_TEST_POSTGRES=postgres://postgres@127.0.0.1:5432/eosutils_test?sslmode=disable go test ./store/
+func testPostgres(t *testing.T) string {
+	dsn := os.Getenv("EOSUTILS_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("EOSUTILS_TEST_POSTGRES not set")
+	}
+	return dsn
+}
+
+func TestPostgresBulkUpsert(t *testing.T) {
+	var backend postgresBackend
+	dbmap, err := backend.Open(testPostgres(t))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer dbmap.Db.Close()
+
+	if _, err = dbmap.Exec("DROP TABLE IF EXISTS BulkTest"); nil != err {
+		t.Fatal(err)
+	}
+	if _, err = dbmap.Exec("CREATE TABLE BulkTest (Account text not null primary key, Amount bigint, Memo text default 'none')"); nil != err {
+		t.Fatal(err)
+	}
+	defer dbmap.Exec("DROP TABLE BulkTest")
+	if _, err = dbmap.Exec("INSERT INTO BulkTest (Account, Amount) VALUES (?, ?)", "alice", 1); nil != err {
+		t.Fatal(err)
+	}
+
+	// alice 已存在，bob 在同一批里出现两次，以最后一行为准
+	rows := [][]interface{}{{"alice", 10}, {"bob", 2}, {"carol", 3}, {"bob", 20}}
+	if err = backend.BulkUpsert(dbmap, "BulkTest", []string{"Account"}, []string{"Account", "Amount"}, rows); nil != err {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"alice": 10, "bob": 20, "carol": 3}
+	for account, amount := range want {
+		got, err := dbmap.SelectInt("SELECT Amount FROM BulkTest WHERE Account=?", account)
+		if nil != err || got != amount {
+			t.Errorf("%s : amount %d, want %d, err %v", account, got, amount, err)
+		}
+	}
+	if n, err := dbmap.SelectInt("SELECT COUNT(*) FROM BulkTest WHERE Memo='none'"); nil != err || n != 3 {
+		t.Errorf("defaults : %d rows, err %v", n, err)
+	}
+
+	// 临时表 ON COMMIT DROP，同一连接上可以再来一批
+	if err = backend.BulkUpsert(dbmap, "BulkTest", []string{"Account"}, []string{"Account", "Amount"}, [][]interface{}{{"dave", 4}}); nil != err {
+		t.Fatal(err)
+	}
+	if n, err := dbmap.SelectInt("SELECT COUNT(*) FROM BulkTest"); nil != err || n != 4 {
+		t.Errorf("after second batch : %d rows, err %v", n, err)
+	}
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recordDriver : 不连库，记下收到的 SQL，Exec 和 Query 都返回空结果
+type recordDriver struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (d *recordDriver) Open(name string) (driver.Conn, error) {
+	return recordConn{d}, nil
+}
+
+func (d *recordDriver) take() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	queries := d.queries
+	d.queries = nil
+	return queries
+}
+
+type recordConn struct {
+	d *recordDriver
+}
+
+func (c recordConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	c.d.queries = append(c.d.queries, query)
+	c.d.mu.Unlock()
+	return recordStmt{}, nil
+}
+func (recordConn) Close() error              { return nil }
+func (recordConn) Begin() (driver.Tx, error) { return recordTx{}, nil }
+
+type recordTx struct{}
+
+func (recordTx) Commit() error   { return nil }
+func (recordTx) Rollback() error { return nil }
+
+type recordStmt struct{}
+
+func (recordStmt) Close() error  { return nil }
+func (recordStmt) NumInput() int { return -1 }
+func (recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (recordStmt) Query(args []driver.Value) (driver.Rows, error) { return recordRows{}, nil }
+
+type recordRows struct{}
+
+func (recordRows) Columns() []string              { return []string{"n"} }
+func (recordRows) Close() error                   { return nil }
+func (recordRows) Next(dest []driver.Value) error { return io.EOF }
+
+var (
+	sqliteRecorder   = &recordDriver{}
+	postgresRecorder = &recordDriver{}
+)
+
+func init() {
+	// sqlite 直接用驱动，PostgreSQL 和 postgresDriver 一样在外面包一层 rebindDriver
+	sql.Register("eosutils-record-sqlite", sqliteRecorder)
+	sql.Register("eosutils-record-postgres", rebindDriver{postgresRecorder})
+}
+
+func TestRebindDriver(t *testing.T) {
+	upsert := UpsertSQL("VoteInfo", []string{"SeqNum", "Voter"}, "SeqNum", "BlockNum", "Voter", "Quantity")
+	ignore := InsertIgnoreSQL("OptOut", "Account", "Memo")
+	query := "SELECT Account FROM OptOut WHERE Memo<>'why?' AND Account=? LIMIT ?"
+
+	for _, c := range []struct {
+		backend string
+		rec     *recordDriver
+		want    []string
+	}{
+		{BackendSqlite, sqliteRecorder, []string{
+			"INSERT INTO VoteInfo (SeqNum,BlockNum,Voter,Quantity) VALUES (?,?,?,?) ON CONFLICT (SeqNum,Voter) " +
+				"DO UPDATE SET BlockNum=excluded.BlockNum, Quantity=excluded.Quantity",
+			"INSERT INTO OptOut (Account,Memo) VALUES (?,?) ON CONFLICT DO NOTHING",
+			"SELECT Account FROM OptOut WHERE Memo<>'why?' AND Account=? LIMIT ?",
+			"INSERT INTO OptOut (Account,Memo) VALUES (?,?) ON CONFLICT DO NOTHING",
+		}},
+		{BackendPostgres, postgresRecorder, []string{
+			"INSERT INTO VoteInfo (SeqNum,BlockNum,Voter,Quantity) VALUES ($1,$2,$3,$4) ON CONFLICT (SeqNum,Voter) " +
+				"DO UPDATE SET BlockNum=excluded.BlockNum, Quantity=excluded.Quantity",
+			"INSERT INTO OptOut (Account,Memo) VALUES ($1,$2) ON CONFLICT DO NOTHING",
+			"SELECT Account FROM OptOut WHERE Memo<>'why?' AND Account=$1 LIMIT $2",
+			"INSERT INTO OptOut (Account,Memo) VALUES ($1,$2) ON CONFLICT DO NOTHING",
+		}},
+	} {
+		t.Run(c.backend, func(t *testing.T) {
+			db, err := sql.Open("eosutils-record-"+c.backend, "")
+			if nil != err {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			c.rec.take()
+
+			if _, err = db.Exec(upsert, 1, 100, "alice", 10); nil != err {
+				t.Fatal(err)
+			}
+			if _, err = db.Exec(ignore, "bob", "unsubscribe"); nil != err {
+				t.Fatal(err)
+			}
+			rows, err := db.Query(query, "bob", 10)
+			if nil != err {
+				t.Fatal(err)
+			}
+			rows.Close()
+			// 事务里的语句同样要改写
+			tx, err := db.Begin()
+			if nil != err {
+				t.Fatal(err)
+			}
+			if _, err = tx.Exec(ignore, "carol", "退订"); nil != err {
+				t.Fatal(err)
+			}
+			if err = tx.Commit(); nil != err {
+				t.Fatal(err)
+			}
+
+			if got := c.rec.take(); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("prepared\n%q\nwant\n%q", got, c.want)
+			}
+		})
+	}
+}
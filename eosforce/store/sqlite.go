@@ -0,0 +1,80 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var pragmas = []string{
+	"PRAGMA synchronous=NORMAL",
+	"PRAGMA page_size=8192",
+	"PRAGMA cache_size=204800",
+	"PRAGMA temp_store=MEMORY",
+}
+
+// sqliteBackend : 本地 sqlite 文件
+type sqliteBackend struct{}
+
+func (sqliteBackend) Name() string {
+	return BackendSqlite
+}
+
+func (sqliteBackend) Open(path string) (*gorp.DbMap, error) {
+	db, err := sql.Open("sqlite3", path)
+	if nil != err {
+		log.Errorf("store.Open - open %s failed : %v", path, err)
+		return nil, err
+	}
+	// 发送goroutine会并发开事务，sqlite只用一个连接，避免 database is locked
+	db.SetMaxOpenConns(1)
+	dbmap := &gorp.DbMap{Db: db, Dialect: gorp.SqliteDialect{}}
+	for _, pragma := range pragmas {
+		if _, err = dbmap.Exec(pragma); nil != err {
+			log.Warnf("store.Open - '%s' failed : %v", pragma, err)
+		}
+	}
+	return dbmap, nil
+}
+
+func (sqliteBackend) Empty(dbmap *gorp.DbMap) (bool, error) {
+	n, err := dbmap.SelectInt("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name NOT IN ('schema_version','sqlite_sequence')")
+	return n == 0, err
+}
+
+// Backup : 用 VACUUM INTO 把库复制到 path.bak-v<版本>-<时间>
+func (sqliteBackend) Backup(dbmap *gorp.DbMap, path string, version int) (string, error) {
+	dst := fmt.Sprintf("%s.bak-v%d-%s", path, version, time.Now().Format("20060102150405"))
+	if _, err := os.Stat(dst); nil == err {
+		return "", fmt.Errorf("backup %s already exists", dst)
+	}
+	if _, err := dbmap.Exec("VACUUM INTO ?", dst); nil != err {
+		return "", err
+	}
+	return dst, nil
+}
+
+// BulkUpsert : sqlite 没有 COPY，在一个事务里逐行写，已经足够快
+func (sqliteBackend) BulkUpsert(dbmap *gorp.DbMap, table string, keys, cols []string, rows [][]interface{}) error {
+	trans, err := dbmap.Db.Begin()
+	if nil != err {
+		return err
+	}
+	stmt, err := trans.Prepare(UpsertSQL(table, keys, cols...))
+	if nil != err {
+		trans.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		if _, err = stmt.Exec(row...); nil != err {
+			trans.Rollback()
+			return err
+		}
+	}
+	return trans.Commit()
+}
@@ -0,0 +1,146 @@
+// Package store 打开各工具共用的数据库，支持 sqlite 和 PostgreSQL。
+//
+// 用 DSN 选择后端：postgres:// 或 postgresql:// 开头的是 PostgreSQL，
+// 其余（可以带 sqlite:// 前缀）是 sqlite 文件名。多人的服务共用一个库时用 PostgreSQL。
+//
+// 各包的 SQL 只用两边都支持的写法：占位符一律用 ?，PostgreSQL 下由驱动改写成 $n；
+// 覆盖写入用 UpsertSQL，忽略重复用 InsertIgnoreSQL；布尔值用 TRUE/FALSE。
+//
+// 表结构由 Migrations 按版本升级，当前版本记在 schema_version 表。
+// 改动表结构时在 Migrations 末尾追加一个版本，不要修改已有的。
+package store
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/logging"
+)
+
+var log = logging.New("store")
+
+// Backend : 一种数据库的实现
+type Backend interface {
+	// Name : 后端名，也是 Migration 中 SQL 的键
+	Name() string
+	// Open : 打开库并设置好 gorp 的方言
+	Open(dsn string) (*gorp.DbMap, error)
+	// Empty : 库中除了 schema_version 之外有没有表
+	Empty(dbmap *gorp.DbMap) (bool, error)
+	// Backup : 升级前备份，返回备份的位置
+	Backup(dbmap *gorp.DbMap, dsn string, version int) (string, error)
+	// BulkUpsert : 批量覆盖写入，rows 中每行的值和 cols 一一对应
+	BulkUpsert(dbmap *gorp.DbMap, table string, keys, cols []string, rows [][]interface{}) error
+}
+
+// 后端名
+const (
+	BackendSqlite   = "sqlite"
+	BackendPostgres = "postgres"
+)
+
+// Parse : 按 DSN 选择后端，返回后端和交给它的 DSN
+func Parse(dsn string) (Backend, string) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return postgresBackend{}, dsn
+	}
+	return sqliteBackend{}, strings.TrimPrefix(dsn, "sqlite://")
+}
+
+// Redact : 隐去 DSN 中的密码，用于日志和报错
+func Redact(dsn string) string {
+	if u, err := url.Parse(dsn); nil == err && u.User != nil {
+		return u.Redacted()
+	}
+	return dsn
+}
+
+// BackendOf : 打开的库属于哪个后端
+func BackendOf(dbmap *gorp.DbMap) Backend {
+	if _, ok := dbmap.Dialect.(postgresDialect); ok {
+		return postgresBackend{}
+	}
+	return sqliteBackend{}
+}
+
+// open : 打开 dsn 并登记 schema_version 表，不检查版本
+func open(dsn string) (*gorp.DbMap, Backend, error) {
+	backend, dsn := Parse(dsn)
+	dbmap, err := backend.Open(dsn)
+	if nil != err {
+		return nil, nil, err
+	}
+	if err = addVersionTable(dbmap); nil != err {
+		log.Errorf("store.Open - create schema_version failed : %v", err)
+		dbmap.Db.Close()
+		return nil, nil, err
+	}
+	return dbmap, backend, nil
+}
+
+// Open : 打开 dsn 指定的库，addTables 注册各自的表。
+// 表由 Migrations 创建：新库直接升级到最新，老库版本不对时返回 *ErrOutdated，需要先执行 migrate
+func Open(dsn string, addTables ...func(*gorp.DbMap)) (*gorp.DbMap, error) {
+	dbmap, backend, err := open(dsn)
+	if nil != err {
+		return nil, err
+	}
+	version, err := Version(dbmap)
+	if nil != err {
+		dbmap.Db.Close()
+		return nil, err
+	}
+	if version == 0 {
+		empty, err := backend.Empty(dbmap)
+		if nil != err {
+			dbmap.Db.Close()
+			return nil, err
+		}
+		if empty {
+			if err = migrate(dbmap, backend, 0); nil != err {
+				dbmap.Db.Close()
+				return nil, err
+			}
+			version = Latest()
+		}
+	}
+	if version != Latest() {
+		dbmap.Db.Close()
+		return nil, &ErrOutdated{Path: Redact(dsn), Version: version}
+	}
+
+	for _, add := range addTables {
+		add(dbmap)
+	}
+	return dbmap, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// UpsertSQL : 按 keys 冲突时覆盖其余字段的 INSERT，参数顺序同 cols
+func UpsertSQL(table string, keys []string, cols ...string) string {
+	var sets []string
+	for _, col := range cols {
+		isKey := false
+		for _, key := range keys {
+			isKey = isKey || key == col
+		}
+		if !isKey {
+			sets = append(sets, col+"=excluded."+col)
+		}
+	}
+	action := "DO NOTHING"
+	if len(sets) > 0 {
+		action = "DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+	return "INSERT INTO " + table + " (" + strings.Join(cols, ",") + ") VALUES (" + placeholders(len(cols)) +
+		") ON CONFLICT (" + strings.Join(keys, ",") + ") " + action
+}
+
+// InsertIgnoreSQL : 已存在时忽略的 INSERT，参数顺序同 cols
+func InsertIgnoreSQL(table string, cols ...string) string {
+	return "INSERT INTO " + table + " (" + strings.Join(cols, ",") + ") VALUES (" + placeholders(len(cols)) + ") ON CONFLICT DO NOTHING"
+}
@@ -0,0 +1,63 @@
+package store
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"no placeholder", "SELECT * FROM VoteInfo", "SELECT * FROM VoteInfo"},
+		{"in order", "SELECT * FROM VoteInfo WHERE Voter=? AND BPName=? LIMIT ?",
+			"SELECT * FROM VoteInfo WHERE Voter=$1 AND BPName=$2 LIMIT $3"},
+		{"string literal", "SELECT '?' , Memo FROM Payout WHERE Memo<>'why?' AND ID=?",
+			"SELECT '?' , Memo FROM Payout WHERE Memo<>'why?' AND ID=$1"},
+		{"escaped quote", "UPDATE Payout SET Memo='it''s ?' WHERE ID=? AND Status=?",
+			"UPDATE Payout SET Memo='it''s ?' WHERE ID=$1 AND Status=$2"},
+		{"quoted identifier", `SELECT "a?b" FROM t WHERE x=?`, `SELECT "a?b" FROM t WHERE x=$1`},
+		{"many", "VALUES (?,?,?,?,?,?,?,?,?,?,?)", "VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)"},
+	}
+	for _, c := range cases {
+		if got := Rebind(c.query); got != c.want {
+			t.Errorf("%s : Rebind(%q) = %q, want %q", c.name, c.query, got, c.want)
+		}
+	}
+}
+
+func TestUpsertSQL(t *testing.T) {
+	cases := []struct {
+		table string
+		keys  []string
+		cols  []string
+		want  string
+	}{
+		{"VoteInfo", []string{"SeqNum", "Voter"}, []string{"SeqNum", "BlockNum", "Voter", "Quantity"},
+			"INSERT INTO VoteInfo (SeqNum,BlockNum,Voter,Quantity) VALUES (?,?,?,?) ON CONFLICT (SeqNum,Voter) " +
+				"DO UPDATE SET BlockNum=excluded.BlockNum, Quantity=excluded.Quantity"},
+		{"AccountInfo", []string{"Account"}, []string{"Account", "Amount"},
+			"INSERT INTO AccountInfo (Account,Amount) VALUES (?,?) ON CONFLICT (Account) DO UPDATE SET Amount=excluded.Amount"},
+		{"OptOutScan", []string{"Sender"}, []string{"Sender"},
+			"INSERT INTO OptOutScan (Sender) VALUES (?) ON CONFLICT (Sender) DO NOTHING"},
+	}
+	for _, c := range cases {
+		if got := UpsertSQL(c.table, c.keys, c.cols...); got != c.want {
+			t.Errorf("UpsertSQL(%s) =\n%s\nwant\n%s", c.table, got, c.want)
+		}
+	}
+}
+
+func TestInsertIgnoreSQL(t *testing.T) {
+	want := "INSERT INTO OptOut (Account,Memo) VALUES (?,?) ON CONFLICT DO NOTHING"
+	if got := InsertIgnoreSQL("OptOut", "Account", "Memo"); got != want {
+		t.Errorf("InsertIgnoreSQL = %s, want %s", got, want)
+	}
+}
+
+func TestLastPerKey(t *testing.T) {
+	rows := [][]interface{}{{"a", 1}, {"b", 2}, {"a", 3}}
+	got := lastPerKey([]string{"Account"}, []string{"Account", "Amount"}, rows)
+	if len(got) != 2 || got[0][0] != "b" || got[1][0] != "a" || got[1][1] != 3 {
+		t.Errorf("lastPerKey = %v", got)
+	}
+}
@@ -0,0 +1,59 @@
+package voters
+
+import (
+	"time"
+
+	"github.com/go-gorp/gorp"
+)
+
+// AsOf : 一个历史时刻，按 block 或时间截止，都给了时两个条件都要满足
+type AsOf struct {
+	BlockNum uint64    // 大于0时只算不晚于这个 block 的投票
+	Time     time.Time // 非零时只算不晚于这个时间的投票
+}
+
+// Cond : 截止条件，以 " AND " 开头，没有条件时为空
+func (at AsOf) Cond() (string, []interface{}) {
+	var cond string
+	var args []interface{}
+	if at.BlockNum > 0 {
+		cond += " AND BlockNum<=?"
+		args = append(args, at.BlockNum)
+	}
+	if !at.Time.IsZero() {
+		cond += " AND BlockTime<=?"
+		args = append(args, at.Time)
+	}
+	return cond, args
+}
+
+// StandingQuery : 每个投票人在 at 时刻对 bp 的有效投票，即此前最后一次投票，撤票(额度为0)的不算。
+// 返回的 SELECT 选出 VoteInfo 的全部列，表别名为 v，后面可以接 ORDER BY/LIMIT。
+func StandingQuery(bp string, at AsOf) (string, []interface{}) {
+	cond, args := at.Cond()
+	return `SELECT v.* FROM VoteInfo v JOIN
+	(SELECT Voter, MAX(SeqNum) AS Seq FROM VoteInfo WHERE BPName=?` + cond + ` GROUP BY Voter) l
+	ON v.SeqNum=l.Seq AND v.Voter=l.Voter WHERE v.Quantity>0`, append([]interface{}{bp}, args...)
+}
+
+// Standing : 从 db 中的投票记录重建 at 时刻 bp 的投票人，按额度从大到小。
+// 只有 VoteInfo 从最早回溯到了 at 之后，结果才完整，见 Coverage。
+func Standing(exec gorp.SqlExecutor, bp string, at AsOf) (VoteArray, error) {
+	query, args := StandingQuery(bp, at)
+	var votes VoteArray
+	if _, err := exec.Select(&votes, query+" ORDER BY v.Quantity DESC, v.Voter", args...); nil != err {
+		return nil, err
+	}
+	return votes, nil
+}
+
+// Coverage : db 中 bp 最早和最晚的一条投票，没有投票时返回 nil
+func Coverage(exec gorp.SqlExecutor, bp string) (first, last *VoteInfo, err error) {
+	var rows []*VoteInfo
+	if _, err = exec.Select(&rows, `SELECT * FROM VoteInfo WHERE BPName=? AND SeqNum IN
+	(SELECT MIN(SeqNum) FROM VoteInfo WHERE BPName=? UNION SELECT MAX(SeqNum) FROM VoteInfo WHERE BPName=?) ORDER BY SeqNum, Voter`,
+		bp, bp, bp); nil != err || len(rows) == 0 {
+		return nil, nil, err
+	}
+	return rows[0], rows[len(rows)-1], nil
+}
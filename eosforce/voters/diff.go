@@ -0,0 +1,33 @@
+package voters
+
+// 投票变化的类型
+const (
+	ChangeNewVoter  = "new_voter"    // 之前没有投票或已撤票，现在投了
+	ChangeVoterLeft = "voter_left"   // 之前投了，现在撤票
+	ChangeStake     = "stake_change" // 额度变化不小于阈值
+	ChangeNone      = ""
+)
+
+// Diff : 比较同一投票人对同一 BP 的前后两次投票，prev 为 nil 表示之前没有投票
+func Diff(prev, cur *VoteInfo, threshold uint64) string {
+	var old uint64
+	if prev != nil {
+		old = prev.Quantity
+	}
+	switch {
+	case old == 0 && cur.Quantity > 0:
+		return ChangeNewVoter
+	case old > 0 && cur.Quantity == 0:
+		return ChangeVoterLeft
+	case old == 0 && cur.Quantity == 0:
+		return ChangeNone
+	}
+	delta := cur.Quantity - old
+	if old > cur.Quantity {
+		delta = old - cur.Quantity
+	}
+	if delta == 0 || delta < threshold {
+		return ChangeNone
+	}
+	return ChangeStake
+}
@@ -0,0 +1,266 @@
+package voters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+)
+
+// voteProducerData : 标准 eosio voteproducer action 的 data
+//
+//	"data": {
+//	  "voter": "gi2dmmbqgene",
+//	  "proxy": "",
+//	  "producers": ["eoscanadacom", "eoshuobipool"]
+//	}
+type voteProducerData struct {
+	Voter     string   `json:"voter"`
+	Proxy     string   `json:"proxy"`
+	Producers []string `json:"producers"`
+}
+
+// delegateData : delegatebw / undelegatebw 的 data，两者字段名不同，合在一起解析
+type delegateData struct {
+	From       string `json:"from"`
+	Receiver   string `json:"receiver"`
+	StakeNet   string `json:"stake_net_quantity"`
+	StakeCPU   string `json:"stake_cpu_quantity"`
+	UnstakeNet string `json:"unstake_net_quantity"`
+	UnstakeCPU string `json:"unstake_cpu_quantity"`
+	Transfer   bool   `json:"transfer"`
+}
+
+// voterRow : eosio voters 表的一行，staked 为最小单位
+type voterRow struct {
+	Owner     string   `json:"owner"`
+	Proxy     string   `json:"proxy"`
+	Producers []string `json:"producers"`
+	Staked    int64    `json:"staked"`
+	IsProxy   int      `json:"is_proxy"`
+}
+
+// voterState : 回溯过程中一个投票人的投票状态
+type voterState struct {
+	name      string
+	proxy     string
+	producers []string
+	staked    int64 // 最小单位，只用回溯到的 delegatebw/undelegatebw 累加
+}
+
+// rpcError : 解析投票时访问节点失败，和 action 数据本身的错误不同，调用方应重试而不是跳过
+type rpcError struct {
+	err error
+}
+
+func (e *rpcError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rpcError) Unwrap() error {
+	return e.err
+}
+
+// isRPCError : err 是否为访问节点失败
+func isRPCError(err error) bool {
+	var rpcErr *rpcError
+	return errors.As(err, &rpcErr)
+}
+
+// getVoter : 从 eosio voters 表查询 voter 当前的投票状态，没有投过票返回 nil
+func getVoter(nodeURL, voter string) (*voterRow, error) {
+	resp, err := eosapi.GetTableRows(nodeURL, &eosapi.TableQuery{
+		Code:       "eosio",
+		Scope:      "eosio",
+		Table:      "voters",
+		LowerBound: strconv.FormatUint(eosapi.StrToName(voter), 10),
+		Limit:      1,
+	})
+	if nil != err {
+		return nil, &rpcError{err: err}
+	}
+	if len(resp.Rows) == 0 {
+		return nil, nil
+	}
+	var row voterRow
+	if err = json.Unmarshal(resp.Rows[0], &row); nil != err {
+		return nil, err
+	}
+	if row.Owner != voter {
+		return nil, nil
+	}
+	return &row, nil
+}
+
+// voter : 取投票人状态。
+// 从头回溯(FromPos 为0)时抵押额从0开始累加；否则第一次遇到时用 voters 表的当前值作起点。
+func (c *Crawler) voter(name string) (*voterState, error) {
+	if st, ok := c.eosioVoters[name]; ok {
+		return st, nil
+	}
+	if c.eosioVoters == nil {
+		c.eosioVoters = make(map[string]*voterState)
+	}
+	st := &voterState{name: name}
+	if c.FromPos > 0 || c.partial {
+		row, err := getVoter(c.NodeURL, name)
+		if nil != err {
+			return nil, err
+		}
+		if row != nil {
+			st.proxy, st.producers, st.staked = row.Proxy, row.Producers, row.Staked
+		}
+	}
+	c.eosioVoters[name] = st
+	return st, nil
+}
+
+// votesBP : st 直接或通过代理投给了 BP
+func (c *Crawler) votesBP(st *voterState) (bool, error) {
+	producers := st.producers
+	if st.proxy != "" {
+		proxy, err := c.voter(st.proxy)
+		if nil != err {
+			return false, err
+		}
+		producers = proxy.producers
+	}
+	for _, bp := range producers {
+		if bp == c.BP {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// record : 按 st 当前状态生成一条投票记录，没有投给 BP 的额度为0，相当于撤票。
+// before 是 action 之前 st 是否投给了 BP，前后都没有投给 BP 的不生成记录
+func (c *Crawler) record(st *voterState, act *eosapi.Action, before bool) (*VoteInfo, error) {
+	votes, err := c.votesBP(st)
+	if nil != err {
+		return nil, err
+	}
+	if !before && !votes {
+		return nil, nil
+	}
+	info := &VoteInfo{
+		SeqNum:    act.GlobalActionSeq,
+		BlockNum:  act.BlockNum,
+		BlockTime: act.Time(),
+		Voter:     st.name,
+		BPName:    c.BP,
+		Symbol:    c.Symbol,
+	}
+	if votes && st.staked > 0 {
+		info.Quantity = uint64(st.staked) / eosapi.ToUnits(1, c.Precision)
+	}
+	return info, nil
+}
+
+// parseEOSIO : 解析标准 eosio 系统合约中影响投票的 action
+func (c *Crawler) parseEOSIO(act *eosapi.Action) (*VoteInfo, []*VoteInfo, error) {
+	switch act.ActionTrace.Act.Name {
+	case "voteproducer":
+		return c.parseVoteProducer(act)
+	case "delegatebw", "undelegatebw":
+		info, err := c.parseDelegate(act)
+		return info, nil, err
+	}
+	return nil, nil, nil
+}
+
+// parseVoteProducer : 更新投票人的代理和BP列表。
+// 投票人本身是代理时，所有通过它投票的人的结果也随之改变，作为 derived 返回，
+// 它们和 info 的 SeqNum 相同，靠 Voter 区分。
+func (c *Crawler) parseVoteProducer(act *eosapi.Action) (*VoteInfo, []*VoteInfo, error) {
+	var data voteProducerData
+	if err := json.Unmarshal(act.ActionTrace.Act.Data, &data); nil != err {
+		return nil, nil, err
+	}
+	if data.Voter == "" {
+		return nil, nil, fmt.Errorf("no voterName")
+	}
+	st, err := c.voter(data.Voter)
+	if nil != err {
+		return nil, nil, err
+	}
+	before, err := c.votesBP(st)
+	if nil != err {
+		return nil, nil, err
+	}
+	// 通过 st 投票的人，按名字排序，保证每次回溯生成的记录一样
+	var others []*voterState
+	for _, other := range c.eosioVoters {
+		if other.proxy == st.name {
+			others = append(others, other)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].name < others[j].name })
+	othersBefore := make([]bool, len(others))
+	for idx, other := range others {
+		if othersBefore[idx], err = c.votesBP(other); nil != err {
+			return nil, nil, err
+		}
+	}
+
+	st.proxy, st.producers = data.Proxy, data.Producers
+	info, err := c.record(st, act, before)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	var derived []*VoteInfo
+	for idx, other := range others {
+		d, err := c.record(other, act, othersBefore[idx])
+		if nil != err {
+			return nil, nil, err
+		}
+		if d == nil {
+			continue
+		}
+		log.Debugf("%s votes via proxy %s, quantity now %d", other.name, st.name, d.Quantity)
+		derived = append(derived, d)
+	}
+	return info, derived, nil
+}
+
+// parseDelegate : 抵押变化改变投票额度。
+// delegatebw 计入 from，transfer 为 true 时计入 receiver；undelegatebw 从 from 扣除。
+// 只对投给了 BP 的账号生成记录。
+func (c *Crawler) parseDelegate(act *eosapi.Action) (*VoteInfo, error) {
+	var data delegateData
+	if err := json.Unmarshal(act.ActionTrace.Act.Data, &data); nil != err {
+		return nil, err
+	}
+	owner := data.From
+	net, cpu := data.StakeNet, data.StakeCPU
+	sign := int64(1)
+	if act.ActionTrace.Act.Name == "undelegatebw" {
+		net, cpu = data.UnstakeNet, data.UnstakeCPU
+		sign = -1
+	} else if data.Transfer {
+		owner = data.Receiver
+	}
+	var delta int64
+	for _, asset := range []string{net, cpu} {
+		amount, _, err := eosapi.ParseAsset(asset, c.Precision)
+		if nil != err {
+			return nil, err
+		}
+		delta += int64(amount)
+	}
+
+	st, err := c.voter(owner)
+	if nil != err {
+		return nil, err
+	}
+	before, err := c.votesBP(st)
+	if nil != err {
+		return nil, err
+	}
+	st.staked += sign * delta
+	return c.record(st, act, before)
+}
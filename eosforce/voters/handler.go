@@ -0,0 +1,109 @@
+package voters
+
+import (
+	"fmt"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/config"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+)
+
+// VoteFunc : 保存一条投票前的回调，prev 是该投票人之前对这个 BP 的最后一条投票，没有则为 nil。
+// exec 和保存投票是同一个事务，返回错误时整个 block 回滚
+type VoteFunc func(exec gorp.SqlExecutor, prev, cur *VoteInfo) error
+
+// Handler : 从 block 流中解析投给 BP 的投票并保存到 VoteInfo，实现 follow.Handler
+type Handler struct {
+	c      *Crawler
+	OnVote VoteFunc // 可以为 nil
+}
+
+// NewHandler : fromGenesis 为 false 时，标准 eosio 下投票人第一次出现时以 voters 表为起点
+func NewHandler(nodeURL, bp, dialect, symbol string, precision int, fromGenesis bool) *Handler {
+	return &Handler{c: &Crawler{
+		NodeURL:   nodeURL,
+		BP:        bp,
+		Dialect:   dialect,
+		Symbol:    symbol,
+		Precision: precision,
+		partial:   !fromGenesis,
+	}}
+}
+
+// Handle : 只看 eosio 合约的投票相关 action，代理变化引起的其他投票人的记录一起保存。
+// 访问节点失败时返回错误，整个 block 回滚重试；action 数据有误的记日志后跳过
+func (h *Handler) Handle(exec gorp.SqlExecutor, act *eosapi.Action) error {
+	a := &act.ActionTrace.Act
+	if a.Account != "eosio" {
+		return nil
+	}
+	if h.c.Dialect != config.DialectEOSIO && a.Name != "vote" {
+		return nil
+	}
+	info, derived, err := h.c.parse(act)
+	if isRPCError(err) {
+		return err
+	}
+	if nil != err {
+		log.Warnf("voters.Handle - seq %d : %v", act.GlobalActionSeq, err)
+		return nil
+	}
+	if info != nil {
+		derived = append([]*VoteInfo{info}, derived...)
+	}
+	for _, cur := range derived {
+		if cur.BPName != h.c.BP {
+			continue
+		}
+		if err = h.save(exec, cur); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// save : 调用 OnVote 后保存一条投票
+func (h *Handler) save(exec gorp.SqlExecutor, info *VoteInfo) error {
+	if h.OnVote != nil {
+		prev, err := Previous(exec, info.Voter, info.BPName)
+		if nil != err {
+			return err
+		}
+		if err = h.OnVote(exec, prev, info); nil != err {
+			return err
+		}
+	}
+	return Save(exec, info)
+}
+
+// Previous : voter 对 bp 的最后一条投票，没有返回 nil
+func Previous(exec gorp.SqlExecutor, voter, bp string) (*VoteInfo, error) {
+	var rows []*VoteInfo
+	if _, err := exec.Select(&rows, "SELECT * FROM VoteInfo WHERE Voter=? AND BPName=? ORDER BY SeqNum DESC LIMIT 1", voter, bp); nil != err {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return rows[0], nil
+}
+
+// CheckKeys : 库中已有的投票和抵押记录必须和即将写入的记录用同一种 SeqNum。
+// synthetic 为 true 表示即将写入 get_block 合成的 SeqNum，见 eosapi.SyntheticSeq，
+// 两种混在一起时按 SeqNum 取最后一次投票的结果是错的，只能分库保存。
+func CheckKeys(exec gorp.SqlExecutor, synthetic bool) error {
+	cond, have := "SeqNum<?", "history sequence numbers"
+	if !synthetic {
+		cond, have = "SeqNum>=?", "sequence numbers synthesized by follow without traces"
+	}
+	for _, table := range []string{"VoteInfo", "StakeEvent"} {
+		n, err := exec.SelectInt("SELECT count(*) FROM (SELECT 1 FROM "+table+" WHERE "+cond+" LIMIT 1) t", eosapi.SyntheticSeq)
+		if nil != err {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%s already holds rows with %s, use a separate db", table, have)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package voters
+
+import (
+	"time"
+
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+)
+
+// page : 一页 get_actions 的结果
+type page struct {
+	pos  int64
+	resp *eosapi.RespGetActions
+	err  error
+}
+
+// prefetch : 从 pos 开始每页前进 step（reverse 时为负），offset 同 get_actions，
+// 最多 c.Prefetch 个请求同时在路上，按请求顺序送出结果。pos 小于0时不再请求，结果通道随之关闭。
+// c.PerSecond 大于0时所有请求合起来每秒最多发这么多个。stop 关闭后不再发新请求
+func (c *Crawler) prefetch(pos, step, offset int64, stop <-chan struct{}) <-chan *page {
+	n := c.Prefetch
+	if n < 1 {
+		n = 1
+	}
+	var tick <-chan time.Time
+	if c.PerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / c.PerSecond))
+		tick = ticker.C
+		go func() {
+			<-stop
+			ticker.Stop()
+		}()
+	}
+
+	// order 中按请求顺序排着每页的结果通道，加上正在等的一页，最多 n 个请求同时在路上
+	order := make(chan chan *page, n-1)
+	go func() {
+		defer close(order)
+		for ; pos >= 0; pos += step {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-stop:
+					return
+				}
+			}
+			ch := make(chan *page, 1)
+			select {
+			case order <- ch:
+			case <-stop:
+				return
+			}
+			go func(pos int64) {
+				log.Debugf("pos %d, offset %d", pos, offset)
+				resp, err := eosapi.GetActions(c.NodeURL, c.historyAccount(), pos, offset)
+				ch <- &page{pos: pos, resp: resp, err: err}
+			}(pos)
+		}
+	}()
+
+	pages := make(chan *page)
+	go func() {
+		defer close(pages)
+		for ch := range order {
+			p := <-ch
+			select {
+			case pages <- p:
+			case <-stop:
+				return
+			}
+			if p.err != nil {
+				return
+			}
+		}
+	}()
+	return pages
+}
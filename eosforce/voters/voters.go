@@ -0,0 +1,448 @@
+// Package voters 抓取并保存投给某个BP的投票记录。
+//
+// 支持 EOSForce 的 vote 和标准 eosio 的 voteproducer 两种投票方式。
+// 标准 eosio 下会解析代理投票，并按 delegatebw/undelegatebw 跟踪投票人的抵押额。
+package voters
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/config"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/metrics"
+	"github.com/gpmn/eosutils/eosforce/stake"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+var log = logging.New("voters")
+
+// TimeLayout : 命令行参数和报表里的时间格式
+const TimeLayout = "2006-01-02 15:04:05"
+
+// VoteInfo :
+type VoteInfo struct {
+	SeqNum                uint64    // 排序用
+	BlockNum              uint64    // 用作查询终止条件
+	Quantity              uint64    // 投票额度
+	BlockTime             time.Time // 用作查询终止条件
+	Voter, BPName, Symbol string    // 投票人、BP、投票品种
+}
+
+// VoteArray :
+type VoteArray []*VoteInfo
+
+// Len is part of sort.Interface.
+func (va VoteArray) Len() int {
+	return len(va)
+}
+
+// Swap is part of sort.Interface.
+func (va VoteArray) Swap(i, j int) {
+	va[i], va[j] = va[j], va[i]
+}
+
+// Less :
+func (va VoteArray) Less(i, j int) bool {
+	return va[i].BlockTime.Before(va[j].BlockTime)
+}
+
+// voteData : EOSForce vote action 的 data
+//
+//	"data": {
+//	  "voter": "hezdonzshege",
+//	  "bpname": "jiqix",
+//	  "stake": "100.0000 EOS"
+//	}
+type voteData struct {
+	Voter  string `json:"voter"`
+	BPName string `json:"bpname"`
+	Stake  string `json:"stake"`
+}
+
+// AddTables : 在dbmap上注册 VoteInfo 表
+func AddTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(VoteInfo{}, "VoteInfo").SetKeys(false, "SeqNum", "Voter")
+}
+
+var saveVoteSQL = store.UpsertSQL("VoteInfo", []string{"SeqNum", "Voter"}, "SeqNum", "BlockNum", "Quantity", "BlockTime", "Voter", "BPName", "Symbol")
+
+// Save : 保存一条投票
+func Save(dbmap gorp.SqlExecutor, info *VoteInfo) error {
+	defer metrics.ObserveDB("vote", time.Now())
+	_, err := dbmap.Exec(saveVoteSQL, info.SeqNum, info.BlockNum, info.Quantity, info.BlockTime, info.Voter, info.BPName, info.Symbol)
+	return err
+}
+
+// SaveAll : 在一个事务里用预编译语句保存一批投票，回溯时一页一批
+func SaveAll(dbmap *gorp.DbMap, infos []*VoteInfo) error {
+	if len(infos) == 0 {
+		return nil
+	}
+	defer metrics.ObserveDB("votes", time.Now())
+	trans, err := dbmap.Db.Begin()
+	if nil != err {
+		return err
+	}
+	stmt, err := trans.Prepare(saveVoteSQL)
+	if nil != err {
+		trans.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, info := range infos {
+		if _, err = stmt.Exec(info.SeqNum, info.BlockNum, info.Quantity, info.BlockTime, info.Voter, info.BPName, info.Symbol); nil != err {
+			trans.Rollback()
+			return err
+		}
+	}
+	return trans.Commit()
+}
+
+// existing : seqs 中已经在 VoteInfo 里的，每次最多查 500 个，避开 sqlite 的参数个数限制
+func existing(exec gorp.SqlExecutor, seqs []uint64) (map[uint64]bool, error) {
+	const chunk = 500
+	found := make(map[uint64]bool)
+	for len(seqs) > 0 {
+		n := len(seqs)
+		if n > chunk {
+			n = chunk
+		}
+		args := make([]interface{}, n)
+		for idx, seq := range seqs[:n] {
+			args[idx] = seq
+		}
+		var rows []uint64
+		if _, err := exec.Select(&rows, "SELECT SeqNum FROM VoteInfo WHERE SeqNum IN ("+strings.TrimSuffix(strings.Repeat("?,", n), ",")+")", args...); nil != err {
+			return nil, err
+		}
+		for _, seq := range rows {
+			found[seq] = true
+		}
+		seqs = seqs[n:]
+	}
+	return found, nil
+}
+
+// Latest : 每个投票人只保留 SeqNum 最大的一条，按时间排序
+func Latest(votes []*VoteInfo) VoteArray {
+	latest := make(map[string]*VoteInfo) // voter -> 最后一次投票
+	for _, v := range votes {
+		if old, ok := latest[v.Voter]; ok && old.SeqNum > v.SeqNum { // 以后面的为准
+			continue
+		}
+		latest[v.Voter] = v
+	}
+
+	var voteList VoteArray
+	for _, v := range latest {
+		voteList = append(voteList, v)
+	}
+	sort.Sort(voteList)
+	return voteList
+}
+
+// PrintReport : 打印每个投票人的最后一次投票
+func PrintReport(voteList VoteArray) {
+	log.Infof("%-12s -> %-12s  %-12s     @ %s", "VOTER", "BP", "QUANTITY", "LAST VOTE DATE")
+	for _, v := range voteList {
+		log.Infof("%-12s -> %-12s  %-12d %s @ %s", v.Voter, v.BPName, v.Quantity, v.Symbol, v.BlockTime.Format(TimeLayout))
+	}
+}
+
+// Crawler : 通过 /v1/history/get_actions 回溯 BP 的投票记录
+type Crawler struct {
+	NodeURL   string
+	BP        string
+	Dialect   string      // config.DialectEOSForce 或 config.DialectEOSIO
+	Token     string      // 核心币合约，claim 的分红只认它的 inline transfer
+	Symbol    string      // 核心币种
+	Precision int         // 核心币小数位数
+	BeginNum  uint64      // 回溯到哪个block number，0表示回溯到最早
+	FromPos   uint64      // 从哪个 account_action_seq 开始，0 表示正向从最早、反向从最新开始
+	Reverse   bool        // 从新到旧回溯，默认从旧到新
+	Begin     time.Time   // 只统计在 Begin 之后的block
+	End       time.Time   // 只统计不晚于 End 的block
+	OnDup     string      // db已有相同SeqNum时：goon/term/query
+	DB        *gorp.DbMap // 为 nil 时不保存
+	Prefetch  int         // 同时在路上的 get_actions 请求数，小于1按1
+	PerSecond float64     // 每秒最多发多少个 get_actions，0表示不限
+
+	ondupSelection byte
+	eosioVoters    map[string]*voterState // eosio 方言下回溯到的投票人状态
+	partial        bool                   // 不是从头开始，投票人状态以 voters 表为起点
+	lastSeq        int64                  // 上一个处理过的 account_action_seq，用于跳过重叠的窗口
+}
+
+// ParseVote : 解析 EOSForce 的 vote action，stake 必须是 symbol 币种
+func ParseVote(act *eosapi.Action, symbol string) (*VoteInfo, error) {
+	var info voteData
+	if err := json.Unmarshal(act.ActionTrace.Act.Data, &info); nil != err {
+		return nil, err
+	}
+	ss := strings.Split(info.Stake, " ")
+	if len(ss) != 2 || ss[1] != symbol {
+		return nil, fmt.Errorf("stake '%s' is not '%s'", info.Stake, symbol)
+	}
+	quant, err := strconv.ParseFloat(ss[0], 64)
+	if nil != err {
+		return nil, fmt.Errorf("strconv.ParseFloat(%s, 64) failed : %v", ss[0], err)
+	}
+	if info.Voter == "" {
+		return nil, fmt.Errorf("no voterName")
+	}
+	return &VoteInfo{
+		SeqNum:    act.GlobalActionSeq,
+		BlockNum:  act.BlockNum,
+		Quantity:  uint64(quant),
+		BlockTime: act.Time(),
+		Voter:     info.Voter,
+		BPName:    info.BPName,
+		Symbol:    ss[1],
+	}, nil
+}
+
+// checkDup : db已有相同SeqNum时按 OnDup 处理，返回 false 表示终止回溯。dup 来自整页一次的 existing 查询
+func (c *Crawler) checkDup(info *VoteInfo, dup bool) (goon bool, err error) {
+	if !dup {
+		return true, nil
+	}
+	switch c.OnDup {
+	case "query":
+		if c.ondupSelection == 'g' {
+			return true, nil
+		}
+		fmt.Printf(`found duplicated vote @ SeqNum %d. press o/i/t/g to continue.
+overwrite this time(o)/ignore this time(i)/term for all(t)/goon for all(g)
+`, info.BlockNum)
+		if _, err = fmt.Scanf("%c", &c.ondupSelection); nil != err {
+			log.Errorf("fmt.Scanf failed : %v", err)
+			return false, err
+		}
+		if c.ondupSelection == 't' {
+			log.Infof("terminate by dup reaction")
+			return false, nil
+		}
+	case "term":
+		return false, nil
+	}
+	return true, nil
+}
+
+// historyAccount : 投票记录所在的账号。EOSForce 的 vote 会通知 BP，标准 eosio 的 voteproducer 只在 eosio 的历史里
+func (c *Crawler) historyAccount() string {
+	if c.Dialect == config.DialectEOSIO {
+		return "eosio"
+	}
+	return c.BP
+}
+
+// parse : 按方言解析一个 action，不影响投票的返回 nil。
+// derived 是因代理变化而改变的其他投票人的记录，它们和 info 共用一个 SeqNum，和 info 一起保存。
+func (c *Crawler) parse(act *eosapi.Action) (info *VoteInfo, derived []*VoteInfo, err error) {
+	if c.Dialect == config.DialectEOSIO {
+		return c.parseEOSIO(act)
+	}
+	name := act.ActionTrace.Act.Name
+	if name == "newaccount" || name == "claim" || name == "unfreeze" || name == "transfer" || name == "updatebp" {
+		return nil, nil, nil
+	}
+	if name != "vote" {
+		log.Warnf("unknown action %s", name)
+		return nil, nil, nil
+	}
+	info, err = ParseVote(act, c.Symbol)
+	return info, nil, err
+}
+
+// pageSize : 每页请求的 action 数，offset 为 ±(pageSize-1)，相邻两页不重叠
+const pageSize = 100
+
+// Run : 按 Reverse 选择的方向回溯并保存投票，返回回溯范围内的全部有效投票
+func (c *Crawler) Run() ([]*VoteInfo, error) {
+	var votes []*VoteInfo
+	if c.Symbol == "" {
+		c.Symbol = "EOS"
+	}
+	if c.Reverse && c.Dialect == config.DialectEOSIO {
+		return nil, fmt.Errorf("reverse mode does not work with %s dialect, stake changes must be replayed in order", c.Dialect)
+	}
+	// 节点返回的页可能比请求的宽，from_pos 之前(reverse 时之后)的也要跳过
+	c.lastSeq = -1
+	if c.FromPos > 0 && c.Reverse {
+		c.lastSeq = int64(c.FromPos) + 1
+	} else if c.FromPos > 0 {
+		c.lastSeq = int64(c.FromPos) - 1
+	}
+
+	// 提前发出的请求在回溯结束后作废
+	stop := make(chan struct{})
+	defer close(stop)
+	var pages <-chan *page
+	switch {
+	case !c.Reverse:
+		pages = c.prefetch(int64(c.FromPos), pageSize, pageSize-1, stop)
+	case c.FromPos > 0:
+		pages = c.prefetch(int64(c.FromPos), -pageSize, -(pageSize - 1), stop)
+	default:
+		// 最新的一页用 pos=-1 取，拿到它最小的 seq 之后才知道往前的各页从哪里开始
+		resp, err := eosapi.GetActions(c.NodeURL, c.historyAccount(), -1, -(pageSize - 1))
+		if nil != err {
+			return votes, err
+		}
+		if done, err := c.runPage(resp.Actions, &votes); nil != err || done || len(resp.Actions) == 0 {
+			return votes, err
+		}
+		pages = c.prefetch(resp.Actions[0].AccountActionSeq-1, -pageSize, -(pageSize - 1), stop)
+	}
+
+	for p := range pages {
+		if nil != p.err {
+			return votes, p.err
+		}
+		if len(p.resp.Actions) == 0 {
+			log.Infof("no more actions")
+			return votes, nil
+		}
+		if done, err := c.runPage(p.resp.Actions, &votes); nil != err || done {
+			return votes, err
+		}
+	}
+	log.Infof("no more actions")
+	return votes, nil
+}
+
+// inRange : 按回溯方向检查 action 是否在 BeginNum/Begin/End 范围内，stop 表示后面的都不在范围内了
+func (c *Crawler) inRange(act *eosapi.Action, blockTime time.Time) (ok, stop bool) {
+	if c.BeginNum > 0 && act.BlockNum < c.BeginNum {
+		if c.Reverse {
+			log.Infof("act.BlockNum:%d less than begin_num:%d, terminate backtrace", act.BlockNum, c.BeginNum)
+		}
+		return false, c.Reverse
+	}
+	if c.Begin.After(blockTime) {
+		if c.Reverse {
+			log.Infof("block time '%s', before limit begin time '%s', terminate.",
+				blockTime.Format(TimeLayout), c.Begin.Format(TimeLayout))
+		}
+		return false, c.Reverse
+	}
+	if c.End.Before(blockTime) {
+		if !c.Reverse {
+			log.Infof("block time '%s', after end time '%s', terminate.",
+				blockTime.Format(TimeLayout), c.End.Format(TimeLayout))
+		}
+		return false, !c.Reverse
+	}
+	return true, false
+}
+
+// runPage : 处理一页 action。重复检查整页查一次，要保存的投票在返回前一个事务写入，返回 true 表示回溯结束
+func (c *Crawler) runPage(actions []eosapi.Action, votes *[]*VoteInfo) (done bool, err error) {
+	var dups map[uint64]bool
+	if c.DB != nil {
+		seqs := make([]uint64, 0, len(actions))
+		for idx := range actions {
+			seqs = append(seqs, actions[idx].GlobalActionSeq)
+		}
+		if dups, err = existing(c.DB, seqs); nil != err {
+			log.Errorf("check duplicated votes failed : %v", err)
+			return true, err
+		}
+	}
+	var batch []*VoteInfo
+	var events []*stake.Event
+	defer func() {
+		if c.DB == nil {
+			return
+		}
+		if serr := SaveAll(c.DB, batch); nil != serr {
+			log.Errorf("saveVoteInfo failed : %v", serr)
+			if nil == err {
+				done, err = true, serr
+			}
+		}
+		if serr := stake.SaveAll(c.DB, events); nil != serr {
+			log.Errorf("stake.SaveAll failed : %v", serr)
+			if nil == err {
+				done, err = true, serr
+			}
+		}
+	}()
+
+	// 节点总是按 account_action_seq 升序返回，reverse 时倒着处理
+	for n := 0; n < len(actions); n++ {
+		idx := n
+		if c.Reverse {
+			idx = len(actions) - 1 - n
+		}
+		act := &actions[idx]
+		// 窗口重叠或节点多返回的部分跳过
+		if c.lastSeq >= 0 && (!c.Reverse && act.AccountActionSeq <= c.lastSeq || c.Reverse && act.AccountActionSeq >= c.lastSeq) {
+			continue
+		}
+		c.lastSeq = act.AccountActionSeq
+		blockTime, err := time.Parse(eosapi.TimeLayout, act.BlockTime)
+		if nil != err {
+			log.Warnf("time.Parse(%s, %s) failed : %v", eosapi.TimeLayout, act.BlockTime, err)
+			continue
+		}
+		if ok, stop := c.inRange(act, blockTime); stop {
+			return true, nil
+		} else if !ok {
+			continue
+		}
+
+		metrics.ActionProcessed(act.ActionTrace.Act.Account, act.ActionTrace.Act.Name)
+		var ev *stake.Event
+		if c.DB != nil && c.Dialect != config.DialectEOSIO {
+			if ev, err = stake.ParseEvent(act, c.Token, c.Symbol, c.Precision); nil != err {
+				log.Warnf("parse stake event of seq %d failed : %v", act.GlobalActionSeq, err)
+				ev = nil
+			}
+		}
+		infoPtr, derived, err := c.parse(act)
+		if isRPCError(err) {
+			log.Errorf("parse vote of seq %d failed : %v", act.GlobalActionSeq, err)
+			return true, err
+		}
+		if nil != err {
+			log.Warnf("parse vote of seq %d failed : %v", act.GlobalActionSeq, err)
+			continue
+		}
+		records := derived
+		if infoPtr != nil {
+			records = append([]*VoteInfo{infoPtr}, derived...)
+		}
+		if len(records) == 0 {
+			// unfreeze/claim 没有投票记录，不参与重复检查，覆盖写入即可
+			if ev != nil {
+				events = append(events, ev)
+			}
+			continue
+		}
+		goon, err := c.checkDup(records[0], dups[act.GlobalActionSeq])
+		if nil != err {
+			return true, err
+		}
+		if !goon {
+			return true, nil
+		}
+		if c.ondupSelection == 'i' {
+			c.ondupSelection = 0
+		} else {
+			batch = append(batch, records...)
+			if ev != nil {
+				events = append(events, ev)
+			}
+		}
+		*votes = append(*votes, records...)
+	}
+	return false, nil
+}
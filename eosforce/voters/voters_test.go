@@ -0,0 +1,245 @@
+package voters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/eosapi"
+	"github.com/gpmn/eosutils/eosforce/store"
+)
+
+// testDB : 临时目录下的新 sqlite 库，已升级到最新版本
+func testDB(tb testing.TB) *gorp.DbMap {
+	dbmap, err := store.Open(filepath.Join(tb.TempDir(), "voters.db"), AddTables)
+	if nil != err {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { dbmap.Db.Close() })
+	return dbmap
+}
+
+// benchPage : 第 n 页的 pageSize 条投票，SeqNum 不和其他页重复
+func benchPage(n int) []*VoteInfo {
+	page := make([]*VoteInfo, pageSize)
+	for idx := range page {
+		seq := uint64(n*pageSize + idx + 1)
+		page[idx] = &VoteInfo{
+			SeqNum:    seq,
+			BlockNum:  seq,
+			Quantity:  seq % 1000,
+			BlockTime: time.Unix(1528617600+int64(seq), 0).UTC(),
+			Voter:     fmt.Sprintf("voter%d", seq%5000),
+			BPName:    "bpa",
+			Symbol:    "EOS",
+		}
+	}
+	return page
+}
+
+// BenchmarkSaveVoteInfo : 每次操作保存一页投票，row 是逐条 Save，batch 是回溯时用的 SaveAll
+func BenchmarkSaveVoteInfo(b *testing.B) {
+	b.Run("row", func(b *testing.B) {
+		dbmap := testDB(b)
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			for _, info := range benchPage(n) {
+				if err := Save(dbmap, info); nil != err {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		dbmap := testDB(b)
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			if err := SaveAll(dbmap, benchPage(n)); nil != err {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// historyServer : 用 testdata/get_actions.json 中录好的 action 充当 history 插件，按 pos/offset 切片返回。
+// extra 大于0时每页两边各多返回 extra 条，和相邻的页重叠
+type historyServer struct {
+	actions []json.RawMessage
+	extra   int64
+
+	mu       sync.Mutex
+	requests [][2]int64 // 收到的 pos, offset
+}
+
+func newHistoryServer(t *testing.T, extra int64) (*historyServer, *httptest.Server) {
+	buf, err := ioutil.ReadFile("testdata/get_actions.json")
+	if nil != err {
+		t.Fatal(err)
+	}
+	var recorded struct {
+		Actions []json.RawMessage `json:"actions"`
+	}
+	if err = json.Unmarshal(buf, &recorded); nil != err {
+		t.Fatal(err)
+	}
+	h := &historyServer{actions: recorded.Actions, extra: extra}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return h, srv
+}
+
+func (h *historyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Pos    string `json:"pos"`
+		Offset string `json:"offset"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); nil != err || r.URL.Path != "/v1/history/get_actions" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	pos, _ := strconv.ParseInt(req.Pos, 10, 64)
+	offset, _ := strconv.ParseInt(req.Offset, 10, 64)
+	h.mu.Lock()
+	h.requests = append(h.requests, [2]int64{pos, offset})
+	h.mu.Unlock()
+
+	last := int64(len(h.actions)) - 1
+	if pos == -1 {
+		pos = last
+	}
+	lo, hi := pos, pos+offset
+	if offset < 0 {
+		lo, hi = pos+offset, pos
+	}
+	lo, hi = lo-h.extra, hi+h.extra
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > last {
+		hi = last
+	}
+	var resp struct {
+		Actions []json.RawMessage `json:"actions"`
+	}
+	resp.Actions = []json.RawMessage{}
+	if lo <= hi {
+		resp.Actions = h.actions[lo : hi+1]
+	}
+	json.NewEncoder(w).Encode(&resp)
+}
+
+// wantVotes : 录好的 action 中 account_action_seq 在 [from, to] 内的投票的 SeqNum，reverse 时从新到旧
+func (h *historyServer) wantVotes(t *testing.T, from, to int64, reverse bool) []uint64 {
+	var seqs []uint64
+	for idx := from; idx <= to; idx++ {
+		var act eosapi.Action
+		if err := json.Unmarshal(h.actions[idx], &act); nil != err {
+			t.Fatal(err)
+		}
+		if act.ActionTrace.Act.Name == "vote" {
+			seqs = append(seqs, act.GlobalActionSeq)
+		}
+	}
+	if reverse {
+		for i, j := 0, len(seqs)-1; i < j; i, j = i+1, j-1 {
+			seqs[i], seqs[j] = seqs[j], seqs[i]
+		}
+	}
+	return seqs
+}
+
+func TestRunPaging(t *testing.T) {
+	cases := []struct {
+		name     string
+		reverse  bool
+		fromPos  uint64
+		prefetch int
+		extra    int64
+		from, to int64      // 应该得到的 account_action_seq 范围
+		requests [][2]int64 // 为 nil 时不检查
+	}{
+		{"forward", false, 0, 1, 0, 0, 204, [][2]int64{{0, 99}, {100, 99}, {200, 99}, {300, 99}}},
+		{"forward from pos", false, 150, 1, 0, 150, 204, [][2]int64{{150, 99}, {250, 99}}},
+		{"forward prefetch", false, 0, 4, 0, 0, 204, nil},
+		{"reverse from latest", true, 0, 1, 0, 0, 204, [][2]int64{{-1, -99}, {104, -99}, {4, -99}}},
+		{"reverse from pos", true, 150, 1, 0, 0, 150, [][2]int64{{150, -99}, {50, -99}}},
+		{"reverse prefetch", true, 0, 4, 0, 0, 204, nil},
+		{"forward overlapping pages", false, 0, 1, 3, 0, 204, nil},
+		{"reverse overlapping pages", true, 0, 1, 3, 0, 204, nil},
+		{"forward from pos overlapping pages", false, 150, 2, 3, 150, 204, nil},
+		{"reverse from pos overlapping pages", true, 150, 2, 3, 0, 150, nil},
+	}
+	for _, tc := range cases {
+		h, srv := newHistoryServer(t, tc.extra)
+		c := &Crawler{
+			NodeURL:  srv.URL,
+			BP:       "bpa",
+			Dialect:  "eosforce",
+			Symbol:   "EOS",
+			FromPos:  tc.fromPos,
+			Reverse:  tc.reverse,
+			End:      time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC),
+			Prefetch: tc.prefetch,
+		}
+		votes, err := c.Run()
+		if nil != err {
+			t.Fatalf("%s : %v", tc.name, err)
+		}
+		var got []uint64
+		for _, v := range votes {
+			got = append(got, v.SeqNum)
+		}
+		if want := h.wantVotes(t, tc.from, tc.to, tc.reverse); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s : got %d votes %v, want %d %v", tc.name, len(got), got, len(want), want)
+		}
+		// 最后一页处理完之前预取可能已经发出了后面的请求，最多多出 prefetch 个
+		h.mu.Lock()
+		requests := append([][2]int64(nil), h.requests...)
+		h.mu.Unlock()
+		if tc.requests != nil && (len(requests) < len(tc.requests) || len(requests) > len(tc.requests)+tc.prefetch ||
+			!reflect.DeepEqual(requests[:len(tc.requests)], tc.requests)) {
+			t.Errorf("%s : requests %v, want %v", tc.name, requests, tc.requests)
+		}
+		for _, req := range requests {
+			if req[0] < -1 {
+				t.Errorf("%s : requested pos %d", tc.name, req[0])
+			}
+		}
+	}
+}
+
+// TestHandleErrors : 访问节点失败时整个 block 要回滚重试，action 数据有误的跳过
+func TestHandleErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"code": 500, "error": {"code": 3000000, "name": "database_exception", "what": "unavailable"}}`)
+	}))
+	defer srv.Close()
+	h := NewHandler(srv.URL, "bp1", "eosio", "EOS", 4, false)
+	dbmap := testDB(t)
+
+	act := func(data string) *eosapi.Action {
+		var a eosapi.Action
+		a.GlobalActionSeq = 1
+		a.BlockTime = "2024-01-01T00:00:00"
+		a.ActionTrace.Act.Account = "eosio"
+		a.ActionTrace.Act.Name = "voteproducer"
+		a.ActionTrace.Act.Data = json.RawMessage(data)
+		return &a
+	}
+	if err := h.Handle(dbmap, act(`{"voter": "alice", "proxy": "", "producers": ["bp1"]}`)); nil == err {
+		t.Error("get_table_rows failure should fail the block")
+	}
+	if err := h.Handle(dbmap, act(`{"voter": 1}`)); nil != err {
+		t.Errorf("bad action data should be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+// Package webhook 把投票变化、漏块等事件通过 HTTP POST 通知出去。
+//
+// 事件先和产生它的 block 在同一个事务里写入 WebhookQueue 表，再由 Run 按顺序投递，
+// 进程重启不会丢事件。投递失败按 budget.Backoff 退避重试，超过次数标记为 failed。
+//
+// 投票事件的请求体是 Event 的 json，其它事件由 Push 的调用方决定。都带以下请求头：
+//
+//	X-Eosutils-Event: 事件类型，如 new_voter / voter_left / stake_change / missed_round
+//	X-Eosutils-Delivery: 事件ID，重试时不变，接收方可据此去重
+//	X-Eosutils-Signature: sha256=<hex(HMAC-SHA256(secret, 请求体))>
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gpmn/eosutils/eosforce/budget"
+	"github.com/gpmn/eosutils/eosforce/logging"
+	"github.com/gpmn/eosutils/eosforce/voters"
+)
+
+var log = logging.New("webhook")
+
+// 投递状态
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// Event : 发给接收方的事件
+type Event struct {
+	ID          int64  `json:"id"`
+	Event       string `json:"event"`
+	BP          string `json:"bp"`
+	Voter       string `json:"voter"`
+	OldQuantity uint64 `json:"old_quantity"`
+	NewQuantity uint64 `json:"new_quantity"`
+	Symbol      string `json:"symbol"`
+	SeqNum      uint64 `json:"seq_num"`
+	BlockNum    uint64 `json:"block_num"`
+	BlockTime   string `json:"block_time"`
+}
+
+// Delivery : WebhookQueue 中的一条待投递事件
+type Delivery struct {
+	ID        int64
+	URL       string
+	Kind      string
+	Body      string
+	Status    string
+	Attempts  int
+	NextAt    time.Time
+	LastError string
+	CreatedAt time.Time
+}
+
+// AddTables : 在dbmap上注册 WebhookQueue 表
+func AddTables(dbmap *gorp.DbMap) {
+	dbmap.AddTableWithName(Delivery{}, "WebhookQueue").SetKeys(true, "ID")
+}
+
+// Sign : 请求体的签名，即 X-Eosutils-Signature 的值
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify : 接收方校验签名
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Push : 把任意事件写入队列，payload 序列化为请求体，setID 在拿到事件ID后调用，可以为 nil
+func Push(exec gorp.SqlExecutor, url, kind string, payload interface{}, setID func(id int64)) error {
+	d := &Delivery{URL: url, Kind: kind, Status: StatusPending, NextAt: time.Now(), CreatedAt: time.Now()}
+	if err := exec.Insert(d); nil != err {
+		return err
+	}
+	if setID != nil {
+		setID(d.ID)
+	}
+	body, err := json.Marshal(payload)
+	if nil != err {
+		return err
+	}
+	d.Body = string(body)
+	_, err = exec.Update(d)
+	return err
+}
+
+// Enqueue : 把一次投票变化写入队列，kind 见 voters.Change* 常量
+func Enqueue(exec gorp.SqlExecutor, url, kind string, prev, cur *voters.VoteInfo) error {
+	ev := &Event{
+		Event:       kind,
+		BP:          cur.BPName,
+		Voter:       cur.Voter,
+		NewQuantity: cur.Quantity,
+		Symbol:      cur.Symbol,
+		SeqNum:      cur.SeqNum,
+		BlockNum:    cur.BlockNum,
+		BlockTime:   cur.BlockTime.Format(voters.TimeLayout),
+	}
+	if prev != nil {
+		ev.OldQuantity = prev.Quantity
+	}
+	return Push(exec, url, kind, ev, func(id int64) { ev.ID = id })
+}
+
+// Sender : 投递参数
+type Sender struct {
+	Secret      string
+	MaxAttempts int // 超过后标记为 failed
+	Client      *http.Client
+}
+
+// post : 投递一次，2xx 算成功
+func (s *Sender) post(d *Delivery) error {
+	body := []byte(d.Body)
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(body))
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Eosutils-Event", d.Kind)
+	req.Header.Set("X-Eosutils-Delivery", strconv.FormatInt(d.ID, 10))
+	if s.Secret != "" {
+		req.Header.Set("X-Eosutils-Signature", Sign(s.Secret, body))
+	}
+	resp, err := s.Client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d : %s", resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// Deliver : 投递所有到期的事件，返回成功的数量
+func (s *Sender) Deliver(dbmap *gorp.DbMap) (sent int, err error) {
+	var due []*Delivery
+	if _, err = dbmap.Select(&due, "SELECT * FROM WebhookQueue WHERE Status=? AND NextAt<=? ORDER BY ID",
+		StatusPending, time.Now()); nil != err {
+		log.Errorf("webhook.Deliver - select WebhookQueue failed : %v", err)
+		return 0, err
+	}
+	for _, d := range due {
+		perr := s.post(d)
+		d.Attempts++
+		if nil == perr {
+			d.Status, d.LastError = StatusSent, ""
+			sent++
+		} else {
+			log.Warnf("webhook.Deliver - event %d to %s failed %d times : %v", d.ID, d.URL, d.Attempts, perr)
+			d.LastError = perr.Error()
+			d.NextAt = time.Now().Add(budget.Backoff(d.Attempts - 1))
+			if s.MaxAttempts > 0 && d.Attempts >= s.MaxAttempts {
+				d.Status = StatusFailed
+			}
+		}
+		if _, err = dbmap.Update(d); nil != err {
+			log.Errorf("webhook.Deliver - update event %d failed : %v", d.ID, err)
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// Run : 每隔 interval 投递一次，直到 stop 关闭
+func (s *Sender) Run(dbmap *gorp.DbMap, interval time.Duration, stop <-chan struct{}) error {
+	if s.Client == nil {
+		s.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	for {
+		if _, err := s.Deliver(dbmap); nil != err {
+			return err
+		}
+		select {
+		case <-time.After(interval):
+		case <-stop:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"voter":"alice","bp":"bp1","quantity":100}`)
+	signature := Sign("secret", body)
+
+	for _, c := range []struct {
+		name      string
+		secret    string
+		body      string
+		signature string
+		want      bool
+	}{
+		{"round trip", "secret", string(body), signature, true},
+		{"tampered body", "secret", `{"voter":"alice","bp":"bp1","quantity":900}`, signature, false},
+		{"trailing byte", "secret", string(body) + " ", signature, false},
+		{"wrong secret", "other", string(body), signature, false},
+		{"no prefix", "secret", string(body), signature[len("sha256="):], false},
+		{"upper case hex", "secret", string(body), "sha256=" + string(upper(signature[len("sha256="):])), false},
+		{"empty signature", "secret", string(body), "", false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Verify(c.secret, []byte(c.body), c.signature); got != c.want {
+				t.Errorf("Verify = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+// upper : 十六进制转大写
+func upper(s string) []byte {
+	b := []byte(s)
+	for i, c := range b {
+		if 'a' <= c && c <= 'f' {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	return b
+}
+
+func TestPostSigned(t *testing.T) {
+	var (
+		body    []byte
+		headers http.Header
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		headers = r.Header
+	}))
+	defer srv.Close()
+
+	s := &Sender{Secret: "secret", Client: srv.Client()}
+	d := &Delivery{ID: 7, URL: srv.URL, Kind: "vote", Body: `{"voter":"alice"}`}
+	if err := s.post(d); nil != err {
+		t.Fatal(err)
+	}
+	if string(body) != d.Body {
+		t.Errorf("body %q, want %q", body, d.Body)
+	}
+	if headers.Get("X-Eosutils-Event") != "vote" || headers.Get("X-Eosutils-Delivery") != "7" {
+		t.Errorf("headers %v", headers)
+	}
+	if !Verify("secret", body, headers.Get("X-Eosutils-Signature")) {
+		t.Errorf("signature %q does not verify", headers.Get("X-Eosutils-Signature"))
+	}
+
+	// 没有配置密钥时不签名
+	s.Secret = ""
+	if err := s.post(d); nil != err {
+		t.Fatal(err)
+	}
+	if sig := headers.Get("X-Eosutils-Signature"); sig != "" {
+		t.Errorf("unsigned delivery has signature %q", sig)
+	}
+}